@@ -9,29 +9,11 @@ package content
 
 import (
 	"bufio"
-	"flag"
 	"io"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
 	"strings"
 )
 
-var (
-	// Logger holds the global logger that can be override by another logger
-	Logger *log.Logger
-)
-
-func init() {
-	// Init logger
-	Logger = log.New(os.Stdout, "", log.LstdFlags)
-	// If it's a test then
-	if flag.Lookup("test.v") != nil {
-		Logger.SetOutput(ioutil.Discard) // discard logs
-	}
-}
-
 // Options represents the options than can be set when creating a new content
 type Options struct {
 	// Reader holds the reader
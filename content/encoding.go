@@ -0,0 +1,85 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package content
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NegotiateEncoding returns the content encoding that is preferred by the given Accept-Encoding
+// header value among the given encodings (i.e. "br", "gzip"), or an empty string if none of them
+// is acceptable. Encodings with higher quality values are preferred, and the order of the given
+// encodings breaks the ties. An explicit zero quality value (i.e. "gzip;q=0") rejects the encoding.
+func NegotiateEncoding(header string, encodings ...string) string {
+	// Init vars
+	quality := map[string]float64{}
+	wildcard := -1.0
+
+	// Parse the header
+	for _, v := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(v), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if k, pv, ok := strings.Cut(strings.TrimSpace(p), "="); ok && strings.EqualFold(strings.TrimSpace(k), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(pv), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if name == "*" {
+			wildcard = q
+		} else {
+			quality[name] = q
+		}
+	}
+
+	// Pick the encoding of the highest quality
+	result, best := "", 0.0
+	for _, v := range encodings {
+		q, ok := quality[strings.ToLower(v)]
+		if !ok {
+			q = wildcard
+		}
+		if q > best {
+			result, best = v, q
+		}
+	}
+
+	return result
+}
+
+// Compressible returns whether the content of the given content type benefits from compression
+// The content types that are already compressed (i.e. images, audio, video, archives and fonts)
+// and the unknown binary content ("application/octet-stream") are not compressible.
+func Compressible(contentType string) bool {
+	// Init vars
+	ct := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+
+	switch {
+	case ct == "" || ct == "application/octet-stream":
+		return false
+	case ct == "image/svg+xml" || ct == "image/bmp" || ct == "image/x-icon" || ct == "image/vnd.microsoft.icon":
+		return true
+	case strings.HasPrefix(ct, "image/") || strings.HasPrefix(ct, "audio/") || strings.HasPrefix(ct, "video/"):
+		return false
+	case strings.HasPrefix(ct, "font/"):
+		return ct == "font/ttf" || ct == "font/otf"
+	}
+
+	switch ct {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2", "application/x-xz",
+		"application/x-7z-compressed", "application/x-rar-compressed", "application/vnd.rar", "application/zstd",
+		"application/pdf":
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,42 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package content_test
+
+import (
+	"testing"
+
+	"github.com/devfacet/goweb/content"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	Convey("should return the preferred encoding", t, func() {
+		So(content.NegotiateEncoding("", "br", "gzip"), ShouldEqual, "")
+		So(content.NegotiateEncoding("gzip, deflate, br", "br", "gzip"), ShouldEqual, "br")
+		So(content.NegotiateEncoding("gzip, deflate", "br", "gzip"), ShouldEqual, "gzip")
+		So(content.NegotiateEncoding("br;q=0.5, GZIP;q=0.8", "br", "gzip"), ShouldEqual, "gzip")
+		So(content.NegotiateEncoding("br;q=0, gzip", "br", "gzip"), ShouldEqual, "gzip")
+		So(content.NegotiateEncoding("*", "br", "gzip"), ShouldEqual, "br")
+		So(content.NegotiateEncoding("*;q=0, gzip", "br", "gzip"), ShouldEqual, "gzip")
+		So(content.NegotiateEncoding("identity", "br", "gzip"), ShouldEqual, "")
+		So(content.NegotiateEncoding("gzip;q=0", "br", "gzip"), ShouldEqual, "")
+	})
+}
+
+func TestCompressible(t *testing.T) {
+	Convey("should return whether the content type is compressible", t, func() {
+		So(content.Compressible("text/html; charset=utf-8"), ShouldBeTrue)
+		So(content.Compressible("application/json"), ShouldBeTrue)
+		So(content.Compressible("image/svg+xml"), ShouldBeTrue)
+		So(content.Compressible("font/ttf"), ShouldBeTrue)
+		So(content.Compressible(""), ShouldBeFalse)
+		So(content.Compressible("application/octet-stream"), ShouldBeFalse)
+		So(content.Compressible("image/png"), ShouldBeFalse)
+		So(content.Compressible("video/mp4"), ShouldBeFalse)
+		So(content.Compressible("font/woff2"), ShouldBeFalse)
+		So(content.Compressible("Application/GZIP"), ShouldBeFalse)
+	})
+}
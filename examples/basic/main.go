@@ -50,16 +50,16 @@ func main() {
 	for _, v := range pages {
 		p, err := page.New(v)
 		if err != nil {
-			log.Logger.Fatal(err)
+			log.Fatal("failed to create page", "error", err)
 		}
 		if err := web.AddPage(p); err != nil {
-			log.Logger.Fatal(err)
+			log.Fatal("failed to add page", "error", err)
 		}
 	}
 
 	// Listen
 	if err := web.Listen(); err != nil {
-		log.Logger.Fatal(err)
+		log.Fatal("failed to listen", "error", err)
 	}
 }
 
@@ -0,0 +1,198 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	badKey = "!BADKEY"
+)
+
+var (
+	// Discard holds a handler that discards all the records
+	Discard Handler = discardHandler{}
+)
+
+// Record represents a log record
+type Record struct {
+	// Time holds the time of the record
+	Time time.Time
+	// Level holds the level of the record
+	Level Level
+	// Message holds the log message
+	Message string
+	// Fields holds the key-value pairs of the record
+	Fields []interface{}
+}
+
+// Handler represents a log record handler
+// Handlers must be safe for concurrent use.
+type Handler interface {
+	// Enabled returns whether the records of the given level are handled or not
+	Enabled(level Level) bool
+	// Handle handles the given record
+	Handle(r Record) error
+}
+
+// fields calls the given function for each key-value pair of the given fields
+// A key that isn't a string or doesn't have a value is reported by the "!BADKEY" key (same as log/slog).
+func fields(kv []interface{}, f func(key string, value interface{})) {
+	for i := 0; i < len(kv); i++ {
+		k, ok := kv[i].(string)
+		if !ok || i == len(kv)-1 {
+			f(badKey, kv[i])
+			continue
+		}
+		f(k, kv[i+1])
+		i++
+	}
+}
+
+// discardHandler represents a handler that discards all the records
+type discardHandler struct{}
+
+// Enabled returns false
+func (discardHandler) Enabled(Level) bool {
+	return false
+}
+
+// Handle discards the given record
+func (discardHandler) Handle(Record) error {
+	return nil
+}
+
+// writerHandler represents a handler that writes the records to a writer line by line
+type writerHandler struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  Level
+	format func(b *bytes.Buffer, r Record)
+}
+
+// Enabled returns whether the given level is the minimum level of the handler or higher
+func (h *writerHandler) Enabled(level Level) bool {
+	return level >= h.level
+}
+
+// Handle writes the given record by a single write
+func (h *writerHandler) Handle(r Record) error {
+	var b bytes.Buffer
+	h.format(&b, r)
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(b.Bytes())
+	return err
+}
+
+// NewTextHandler returns a handler that writes the records of the given level or higher as text lines
+// i.e. `2017-10-05T10:00:00.000Z INFO listening server=web address=localhost:3000`
+func NewTextHandler(w io.Writer, level Level) Handler {
+	return &writerHandler{w: w, level: level, format: formatText}
+}
+
+// formatText formats the given record as a text line
+func formatText(b *bytes.Buffer, r Record) {
+	b.WriteString(r.Time.Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteByte(' ')
+	b.WriteString(r.Level.String())
+	b.WriteByte(' ')
+	b.WriteString(textValue(r.Message, false))
+	fields(r.Fields, func(k string, v interface{}) {
+		b.WriteByte(' ')
+		b.WriteString(textValue(k, true))
+		b.WriteByte('=')
+		b.WriteString(textValue(fmt.Sprint(v), true))
+	})
+}
+
+// textValue returns the given text value by quoting it if it's needed
+func textValue(s string, field bool) string {
+	if s == "" {
+		if field {
+			return `""`
+		}
+		return s
+	}
+	for _, c := range s {
+		if (field && (c == ' ' || c == '=')) || c == '"' || !unicode.IsPrint(c) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// NewJSONHandler returns a handler that writes the records of the given level or higher as JSON lines
+// i.e. `{"time":"2017-10-05T10:00:00Z","level":"INFO","msg":"listening","address":"localhost:3000"}`
+func NewJSONHandler(w io.Writer, level Level) Handler {
+	return &writerHandler{w: w, level: level, format: formatJSON}
+}
+
+// formatJSON formats the given record as a JSON line
+func formatJSON(b *bytes.Buffer, r Record) {
+	b.WriteString(`{"time":`)
+	jsonValue(b, r.Time.Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	jsonValue(b, r.Level.String())
+	b.WriteString(`,"msg":`)
+	jsonValue(b, r.Message)
+	fields(r.Fields, func(k string, v interface{}) {
+		b.WriteByte(',')
+		jsonValue(b, k)
+		b.WriteByte(':')
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		jsonValue(b, v)
+	})
+	b.WriteByte('}')
+}
+
+// jsonValue writes the given value as JSON (or as a JSON string if it can't be encoded)
+func jsonValue(b *bytes.Buffer, v interface{}) {
+	d, err := json.Marshal(v)
+	if err != nil {
+		d, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(d)
+}
+
+// slogHandler represents a handler that passes the records to a log/slog handler
+type slogHandler struct {
+	h slog.Handler
+}
+
+// NewSlogHandler returns a handler that passes the records to the given log/slog handler
+// i.e. `log.New(log.Options{Handler: log.NewSlogHandler(slog.Default().Handler())})`
+func NewSlogHandler(h slog.Handler) Handler {
+	return &slogHandler{h: h}
+}
+
+// Enabled returns whether the slog handler handles the given level or not
+func (h *slogHandler) Enabled(level Level) bool {
+	return h.h.Enabled(context.Background(), slog.Level(level))
+}
+
+// Handle passes the given record to the slog handler
+func (h *slogHandler) Handle(r Record) error {
+	sr := slog.NewRecord(r.Time, slog.Level(r.Level), r.Message, 0)
+	fields(r.Fields, func(k string, v interface{}) {
+		sr.AddAttrs(slog.Any(k, v))
+	})
+	return h.h.Handle(context.Background(), sr)
+}
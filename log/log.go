@@ -3,28 +3,186 @@
  * For the full copyright and license information, please view the LICENSE.txt file.
  */
 
-// Package log implements a simple logging package
-// Currently it's pretty much a place holder for future implementations.
+// Package log implements a leveled, structured logger that is shared by the packages
 package log
 
 import (
-	"flag"
-	"io/ioutil"
-	"log"
+	stdlog "log"
 	"os"
+	"strings"
+	"time"
 )
 
 var (
-	// Logger holds the global logger that can be override by another logger
-	Logger *log.Logger
+	// Logger holds the global logger that is shared by the packages
+	// It writes text lines to the standard output for the info and higher levels by default, and
+	// can be replaced by another logger before the servers start
+	// i.e. `log.Logger = log.New(log.Options{Handler: log.NewJSONHandler(os.Stderr, log.LevelDebug)})`
+	// or `log.Logger = log.New(log.Options{Handler: log.Discard})` for disabling the logs (i.e. in tests).
+	// Any Leveled implementation can be used (i.e. an adapter of another logging library).
+	Logger Leveled = New(Options{})
 )
 
-func init() {
-	// Init logger
-	Logger = log.New(os.Stdout, "", log.LstdFlags)
+// Leveled represents a leveled, structured logger
+// The log functions take a message and key-value pairs (i.e. `Info("listening", "address", addr)`).
+type Leveled interface {
+	// Enabled returns whether the records of the given level are logged or not
+	Enabled(level Level) bool
+	// Log logs the given message and key-value pairs by the given level
+	Log(level Level, msg string, kv ...interface{})
+	// Debug logs the given message and key-value pairs by the debug level
+	Debug(msg string, kv ...interface{})
+	// Info logs the given message and key-value pairs by the info level
+	Info(msg string, kv ...interface{})
+	// Warn logs the given message and key-value pairs by the warning level
+	Warn(msg string, kv ...interface{})
+	// Error logs the given message and key-value pairs by the error level
+	Error(msg string, kv ...interface{})
+	// With returns a logger that adds the given key-value pairs to all of its records
+	With(kv ...interface{}) Leveled
+}
+
+// Level represents a log level
+// The values are the same as the log/slog levels.
+type Level int
+
+const (
+	// LevelDebug represents the debug level
+	LevelDebug Level = -4
+	// LevelInfo represents the info level
+	LevelInfo Level = 0
+	// LevelWarn represents the warning level
+	LevelWarn Level = 4
+	// LevelError represents the error level
+	LevelError Level = 8
+)
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	}
+	return "ERROR"
+}
+
+// Options represents the options than can be set when creating a new logger
+type Options struct {
+	// Handler holds the handler of the log records (default text lines to the standard output for the info level)
+	Handler Handler
+	// Fields holds the key-value pairs that are added to all the records of the logger (i.e. "app", "dashboard")
+	Fields []interface{}
+}
 
-	// If it's a test then
-	if flag.Lookup("test.v") != nil {
-		Logger.SetOutput(ioutil.Discard) // discard logs
+// New returns a new logger by the given options
+func New(o Options) *Log {
+	// Init the logger
+	logger := Log{
+		isInit:  true,
+		handler: o.Handler,
+		fields:  o.Fields,
 	}
+
+	if logger.handler == nil {
+		logger.handler = NewTextHandler(os.Stdout, LevelInfo)
+	}
+
+	return &logger
+}
+
+// Log represents a leveled, structured logger that passes its records to a handler (see Leveled)
+type Log struct {
+	isInit  bool
+	handler Handler
+	fields  []interface{}
+}
+
+// Handler returns the handler of the logger
+func (logger *Log) Handler() Handler {
+	return logger.handler
+}
+
+// Enabled returns whether the records of the given level are handled or not
+func (logger *Log) Enabled(level Level) bool {
+	return logger.handler.Enabled(level)
+}
+
+// With returns a logger that adds the given key-value pairs to all of its records
+func (logger *Log) With(kv ...interface{}) Leveled {
+	return &Log{
+		isInit:  true,
+		handler: logger.handler,
+		fields:  append(append([]interface{}{}, logger.fields...), kv...),
+	}
+}
+
+// Log logs the given message and key-value pairs by the given level
+func (logger *Log) Log(level Level, msg string, kv ...interface{}) {
+	if !logger.handler.Enabled(level) {
+		return
+	}
+	fields := kv
+	if len(logger.fields) > 0 {
+		fields = append(append([]interface{}{}, logger.fields...), kv...)
+	}
+	logger.handler.Handle(Record{Time: time.Now(), Level: level, Message: msg, Fields: fields})
+}
+
+// Debug logs the given message and key-value pairs by the debug level
+func (logger *Log) Debug(msg string, kv ...interface{}) {
+	logger.Log(LevelDebug, msg, kv...)
+}
+
+// Info logs the given message and key-value pairs by the info level
+func (logger *Log) Info(msg string, kv ...interface{}) {
+	logger.Log(LevelInfo, msg, kv...)
+}
+
+// Warn logs the given message and key-value pairs by the warning level
+func (logger *Log) Warn(msg string, kv ...interface{}) {
+	logger.Log(LevelWarn, msg, kv...)
+}
+
+// Error logs the given message and key-value pairs by the error level
+func (logger *Log) Error(msg string, kv ...interface{}) {
+	logger.Log(LevelError, msg, kv...)
+}
+
+// Fatal logs the given message and key-value pairs by the error level and exits the program
+func (logger *Log) Fatal(msg string, kv ...interface{}) {
+	logger.Log(LevelError, msg, kv...)
+	os.Exit(1)
+}
+
+// StdLogger returns a standard library logger that logs its lines by the given level
+// It's useful for the packages that take a *log.Logger (i.e. http.Server.ErrorLog).
+func (logger *Log) StdLogger(level Level) *stdlog.Logger {
+	return StdLogger(logger, level)
+}
+
+// StdLogger returns a standard library logger that logs its lines by the given logger and level
+func StdLogger(logger Leveled, level Level) *stdlog.Logger {
+	return stdlog.New(&stdWriter{logger: logger, level: level}, "", 0)
+}
+
+// Fatal logs the given message and key-value pairs by the global logger and exits the program
+func Fatal(msg string, kv ...interface{}) {
+	Logger.Error(msg, kv...)
+	os.Exit(1)
+}
+
+// stdWriter represents a writer that logs the lines of a standard library logger
+type stdWriter struct {
+	logger Leveled
+	level  Level
+}
+
+// Write logs the given line
+func (sw *stdWriter) Write(p []byte) (int, error) {
+	sw.logger.Log(sw.level, strings.TrimRight(string(p), "\r\n"))
+	return len(p), nil
 }
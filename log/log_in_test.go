@@ -6,6 +6,12 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -16,3 +22,110 @@ func TestCheck(t *testing.T) {
 		So(Logger, ShouldNotBeNil)
 	})
 }
+
+func TestLevel(t *testing.T) {
+	Convey("should return the level names", t, func() {
+		So(LevelDebug.String(), ShouldEqual, "DEBUG")
+		So(LevelInfo.String(), ShouldEqual, "INFO")
+		So(LevelWarn.String(), ShouldEqual, "WARN")
+		So(LevelError.String(), ShouldEqual, "ERROR")
+		So(Level(2).String(), ShouldEqual, "INFO")
+		So(Level(12).String(), ShouldEqual, "ERROR")
+	})
+}
+
+func TestTextHandler(t *testing.T) {
+	Convey("should write the records as text lines", t, func() {
+		var b bytes.Buffer
+		logger := New(Options{Handler: NewTextHandler(&b, LevelInfo), Fields: []interface{}{"app", "web"}})
+		logger.Debug("hidden")
+		So(b.String(), ShouldEqual, "")
+		So(logger.Enabled(LevelDebug), ShouldBeFalse)
+
+		logger.With("server", "s1").Info("listening", "address", "localhost:3000", "tls", true, "error", errors.New("bad thing"), "empty", "", 42)
+		So(regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}\S* INFO listening app=web server=s1 address=localhost:3000 tls=true error="bad thing" empty="" !BADKEY=42\n$`).MatchString(b.String()), ShouldBeTrue)
+
+		b.Reset()
+		logger.Warn(`quoted "message"`, "a=b", "x\ny")
+		So(b.String(), ShouldEndWith, ` WARN "quoted \"message\"" app=web "a=b"="x\ny"`+"\n")
+	})
+}
+
+func TestJSONHandler(t *testing.T) {
+	Convey("should write the records as JSON lines", t, func() {
+		var b bytes.Buffer
+		logger := New(Options{Handler: NewJSONHandler(&b, LevelDebug)})
+		logger.Debug("request", "status", 200, "path", "/", "error", errors.New("bad thing"), "ch", make(chan int))
+
+		var entry map[string]interface{}
+		So(json.Unmarshal(b.Bytes(), &entry), ShouldBeNil)
+		So(entry["level"], ShouldEqual, "DEBUG")
+		So(entry["msg"], ShouldEqual, "request")
+		So(entry["status"], ShouldEqual, 200)
+		So(entry["path"], ShouldEqual, "/")
+		So(entry["error"], ShouldEqual, "bad thing")
+		So(entry["ch"], ShouldStartWith, "0x")
+		So(entry, ShouldContainKey, "time")
+		So(strings.HasPrefix(b.String(), `{"time":`), ShouldBeTrue)
+	})
+}
+
+func TestSlogHandler(t *testing.T) {
+	Convey("should pass the records to the slog handler", t, func() {
+		var b bytes.Buffer
+		logger := New(Options{Handler: NewSlogHandler(slog.NewTextHandler(&b, &slog.HandlerOptions{Level: slog.LevelWarn}))})
+		logger.Info("hidden")
+		So(b.String(), ShouldEqual, "")
+		logger.With("server", "s1").Error("failed", "error", errors.New("bad thing"))
+		So(b.String(), ShouldEndWith, `level=ERROR msg=failed server=s1 error="bad thing"`+"\n")
+	})
+}
+
+func TestStdLogger(t *testing.T) {
+	Convey("should log the lines of the standard library logger", t, func() {
+		var b bytes.Buffer
+		logger := New(Options{Handler: NewJSONHandler(&b, LevelInfo)})
+		logger.StdLogger(LevelError).Printf("http: TLS handshake error from %s", "127.0.0.1")
+
+		var entry map[string]interface{}
+		So(json.Unmarshal(b.Bytes(), &entry), ShouldBeNil)
+		So(entry["level"], ShouldEqual, "ERROR")
+		So(entry["msg"], ShouldEqual, "http: TLS handshake error from 127.0.0.1")
+	})
+}
+
+// testLogger represents a Leveled implementation that records the messages
+type testLogger struct {
+	msgs *[]string
+}
+
+func (l testLogger) Enabled(Level) bool { return true }
+func (l testLogger) Log(level Level, msg string, kv ...interface{}) {
+	*l.msgs = append(*l.msgs, level.String()+" "+msg)
+}
+func (l testLogger) Debug(msg string, kv ...interface{}) { l.Log(LevelDebug, msg, kv...) }
+func (l testLogger) Info(msg string, kv ...interface{})  { l.Log(LevelInfo, msg, kv...) }
+func (l testLogger) Warn(msg string, kv ...interface{})  { l.Log(LevelWarn, msg, kv...) }
+func (l testLogger) Error(msg string, kv ...interface{}) { l.Log(LevelError, msg, kv...) }
+func (l testLogger) With(kv ...interface{}) Leveled      { return l }
+
+func TestLeveled(t *testing.T) {
+	Convey("should use any Leveled implementation as the global logger", t, func() {
+		msgs := []string{}
+		defer func(l Leveled) { Logger = l }(Logger)
+		Logger = testLogger{msgs: &msgs}
+
+		Logger.With("server", "s1").Info("listening")
+		StdLogger(Logger, LevelWarn).Print("http: superfluous response.WriteHeader call")
+		So(msgs, ShouldResemble, []string{"INFO listening", "WARN http: superfluous response.WriteHeader call"})
+	})
+}
+
+func TestDiscard(t *testing.T) {
+	Convey("should discard the records", t, func() {
+		logger := New(Options{Handler: Discard})
+		So(logger.Enabled(LevelError), ShouldBeFalse)
+		So(logger.Handler(), ShouldEqual, Discard)
+		logger.Error("discarded")
+	})
+}
@@ -8,40 +8,47 @@ package page
 
 import (
 	"errors"
-	"flag"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
-)
+	"path"
+	"reflect"
+	"strings"
+	"sync"
 
-var (
-	// Logger holds the global logger that can be override by another logger
-	Logger *log.Logger
+	"github.com/devfacet/goweb/request"
 )
 
-func init() {
-	// Init logger
-	Logger = log.New(os.Stdout, "", log.LstdFlags)
-	// If it's a test then
-	if flag.Lookup("test.v") != nil {
-		Logger.SetOutput(ioutil.Discard) // discard logs
+var (
+	// templateFuncs holds the template functions that are available to all pages
+	// The functions that depend on a request are replaced during execution.
+	templateFuncs = template.FuncMap{
+		"param": func(string) string { return "" },
+		"url":   func(path string) string { return path },
+		"urlfor": func(name string, params ...interface{}) (string, error) {
+			return "", fmt.Errorf("failed to build url for %s due to missing request", name)
+		},
 	}
-}
+)
 
 // Options represents the options than can be set when creating a new page
 type Options struct {
 	// URLPath holds the url path
 	URLPath string
+	// Name holds the route name of the page for building URLs (optional)
+	Name string
 	// MatchAll matches everything after slash
 	MatchAll bool
 	// FilePath holds the file path
 	FilePath string
 	// FileSystem holds the file system
 	FileSystem *http.FileSystem
+	// FS holds the file system as an fs.FS (i.e. an embed.FS) which is used instead of FileSystem
+	// The file path is relative to the root of the file system (i.e. "templates/index.html").
+	FS fs.FS
 	// Content holds the page content
 	Content string
 	// TemplateData holds the template data
@@ -54,6 +61,7 @@ func New(o Options) (*Page, error) {
 	page := Page{
 		isInit:       true,
 		urlPath:      o.URLPath,
+		name:         o.Name,
 		matchAll:     o.MatchAll,
 		filePath:     o.FilePath,
 		fileSystem:   o.FileSystem,
@@ -69,7 +77,13 @@ func New(o Options) (*Page, error) {
 	// If the file path is not empty then
 	if page.filePath != "" {
 		// Read the file and set the template content
-		if page.fileSystem != nil {
+		if o.FS != nil {
+			b, err := fs.ReadFile(o.FS, strings.TrimPrefix(path.Clean("/"+page.filePath), "/"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file due to %s", err.Error())
+			}
+			page.content = string(b)
+		} else if page.fileSystem != nil {
 			f, err := (*page.fileSystem).Open(page.filePath)
 			if err != nil {
 				return nil, fmt.Errorf("failed to open file due to %s", err.Error())
@@ -90,10 +104,15 @@ func New(o Options) (*Page, error) {
 	// If the content is not empty then
 	if page.content != "" {
 		var err error
-		page.template, err = template.New(page.urlPath).Parse(page.content)
+		page.template, err = template.New(page.urlPath).Funcs(templateFuncs).Parse(page.content)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse template due to %s", err.Error())
 		}
+		// Keep a copy that is never executed so it can be cloned for per request functions
+		if page.base, err = page.template.Clone(); err != nil {
+			return nil, fmt.Errorf("failed to clone template due to %s", err.Error())
+		}
+		page.clones = &sync.Pool{}
 	}
 
 	return &page, nil
@@ -103,12 +122,49 @@ func New(o Options) (*Page, error) {
 type Page struct {
 	isInit       bool
 	urlPath      string
+	name         string
 	matchAll     bool
 	filePath     string
 	fileSystem   *http.FileSystem
 	content      string
 	templateData interface{}
 	template     *template.Template
+	base         *template.Template
+	clones       *sync.Pool
+}
+
+// templateClone represents a clone of the page template whose functions call the functions of the current execution
+// Clones are pooled so the template is cloned once per concurrent execution instead of once per execution.
+type templateClone struct {
+	t     *template.Template
+	funcs template.FuncMap
+}
+
+// clone returns a template clone from the pool (or a new one if the pool is empty)
+func (page *Page) clone() (*templateClone, error) {
+	if v := page.clones.Get(); v != nil {
+		return v.(*templateClone), nil
+	}
+
+	tc := &templateClone{}
+	t, err := page.base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	// The given functions are validated by TemplateExecuteFuncs so the type assertions are safe
+	tc.t = t.Funcs(template.FuncMap{
+		"param": func(name string) string {
+			return tc.funcs["param"].(func(string) string)(name)
+		},
+		"url": func(path string) string {
+			return tc.funcs["url"].(func(string) string)(path)
+		},
+		"urlfor": func(name string, params ...interface{}) (string, error) {
+			return tc.funcs["urlfor"].(func(string, ...interface{}) (string, error))(name, params...)
+		},
+	})
+
+	return tc, nil
 }
 
 // URLPath returns the url path
@@ -116,11 +172,21 @@ func (page *Page) URLPath() string {
 	return page.urlPath
 }
 
+// Name returns the route name
+func (page *Page) Name() string {
+	return page.name
+}
+
 // MatchAll returns whether the page url path should match all or not
 func (page *Page) MatchAll() bool {
 	return page.matchAll
 }
 
+// TemplateData returns the template data
+func (page *Page) TemplateData() interface{} {
+	return page.templateData
+}
+
 // TemplateExecute executes the template by the given arguments
 // TODO: add 2nd parameter for templateData and use page's templateData if it's nil
 func (page *Page) TemplateExecute(w io.Writer, data interface{}) error {
@@ -141,3 +207,64 @@ func (page *Page) TemplateExecute(w io.Writer, data interface{}) error {
 
 	return nil
 }
+
+// TemplateExecuteFuncs executes the template by the given arguments and template functions
+// The given functions replace the page functions with the same name and type (param, url and urlfor)
+// for this execution only. Other functions are ignored.
+func (page *Page) TemplateExecuteFuncs(w io.Writer, data interface{}, funcs template.FuncMap) error {
+	// If the template is nil then
+	if page.base == nil {
+		return nil
+	}
+
+	// If the given data is nil and the template data is not then
+	if data == nil && page.templateData != nil {
+		data = page.templateData // use the template data
+	}
+
+	// Merge the given functions with the page functions
+	fm := make(template.FuncMap, len(templateFuncs))
+	for k, v := range templateFuncs {
+		fm[k] = v
+		if f, ok := funcs[k]; ok {
+			if reflect.TypeOf(f) != reflect.TypeOf(v) {
+				return fmt.Errorf("failed to execute template due to invalid function %s", k)
+			}
+			fm[k] = f
+		}
+	}
+
+	// Execute a clone of the template with the functions
+	tc, err := page.clone()
+	if err != nil {
+		return fmt.Errorf("failed to clone template due to %s", err.Error())
+	}
+	tc.funcs = fm
+	err = tc.t.Execute(w, data)
+	tc.funcs = nil
+	page.clones.Put(tc)
+	if err != nil {
+		return fmt.Errorf("failed to execute template due to %s", err.Error())
+	}
+
+	return nil
+}
+
+// RequestFuncs returns the template functions for the given request
+// It includes the param (see ParamFuncs), the url function which returns the given path under
+// the path prefix of the request (i.e. `{{url "/users"}}`) and the urlfor function which builds
+// the URL of a named route (i.e. `{{urlfor "user" "id" .ID}}`).
+func RequestFuncs(req *request.Request) template.FuncMap {
+	funcs := ParamFuncs(req.Params())
+	funcs["url"] = req.URL
+	funcs["urlfor"] = req.URLFor
+
+	return funcs
+}
+
+// ParamFuncs returns the template functions for the given path parameters
+func ParamFuncs(params map[string]string) template.FuncMap {
+	return template.FuncMap{
+		"param": func(name string) string { return params[name] },
+	}
+}
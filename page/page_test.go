@@ -8,12 +8,18 @@ package page_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"html/template"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"testing/fstest"
 
 	"github.com/devfacet/goweb/page"
+	"github.com/devfacet/goweb/request"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -52,6 +58,19 @@ func TestNew(t *testing.T) {
 		So(p, ShouldBeNil)
 	})
 
+	Convey("should return a new page with the given file and fs.FS", t, func() {
+		fsys := fstest.MapFS{"templates/test.html": &fstest.MapFile{Data: []byte("<p>{{.Test}}</p>")}}
+		p, err := page.New(page.Options{URLPath: "/test", FilePath: "/templates/test.html", FS: fsys, TemplateData: struct{ Test string }{Test: "foo"}})
+		So(err, ShouldBeNil)
+		var b bytes.Buffer
+		So(p.TemplateExecute(&b, p.TemplateData()), ShouldBeNil)
+		So(b.String(), ShouldEqual, "<p>foo</p>")
+
+		p, err = page.New(page.Options{URLPath: "/test", FilePath: "error.html", FS: fsys})
+		So(err, ShouldBeError, errors.New("failed to read file due to open error.html: file does not exist"))
+		So(p, ShouldBeNil)
+	})
+
 	Convey("should fail to return a new page due to missing file system", t, func() {
 		p, err := page.New(page.Options{URLPath: "/test", FilePath: "error.html"})
 		So(err, ShouldBeError, errors.New("invalid file system"))
@@ -69,6 +88,14 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestName(t *testing.T) {
+	Convey("should return the given name", t, func() {
+		p, err := page.New(page.Options{URLPath: "/test", Name: "test"})
+		So(err, ShouldBeNil)
+		So(p.Name(), ShouldEqual, "test")
+	})
+}
+
 func TestURLPath(t *testing.T) {
 	Convey("should return the given url path", t, func() {
 		pages := []struct {
@@ -135,3 +162,72 @@ func TestTemplateExecute(t *testing.T) {
 		So(p1.TemplateExecute(ioutil.Discard, nil), ShouldBeError, errors.New(`failed to execute template due to template: /test:1:2: executing "/test" at <.Test>: can't evaluate field Test in type struct { test string }`))
 	})
 }
+
+func TestTemplateExecuteFuncs(t *testing.T) {
+	Convey("should execute page template with the given functions", t, func() {
+		p, err := page.New(page.Options{URLPath: "/docs/{slug}", Content: `{{.Test}} {{param "slug"}}`, TemplateData: struct{ Test string }{Test: "test"}})
+		So(err, ShouldBeNil)
+		So(p.TemplateData(), ShouldNotBeNil)
+
+		b := bytes.Buffer{}
+		So(p.TemplateExecuteFuncs(&b, nil, page.ParamFuncs(map[string]string{"slug": "foo"})), ShouldBeNil)
+		So(b.String(), ShouldEqual, "test foo")
+
+		b.Reset()
+		So(p.TemplateExecute(&b, nil), ShouldBeNil)
+		So(b.String(), ShouldEqual, "test ")
+
+		b.Reset()
+		So(p.TemplateExecuteFuncs(&b, nil, page.ParamFuncs(map[string]string{"slug": "bar"})), ShouldBeNil)
+		So(b.String(), ShouldEqual, "test bar")
+	})
+
+	Convey("should fail to execute page template with the given functions", t, func() {
+		p, err := page.New(page.Options{URLPath: "/test", Content: "{{.Test}}", TemplateData: struct{ Foo string }{Foo: "foo"}})
+		So(err, ShouldBeNil)
+		So(p.TemplateExecuteFuncs(ioutil.Discard, nil, nil), ShouldNotBeNil)
+
+		p, err = page.New(page.Options{URLPath: "/test"})
+		So(err, ShouldBeNil)
+		So(p.TemplateExecuteFuncs(ioutil.Discard, nil, nil), ShouldBeNil)
+
+		p, err = page.New(page.Options{URLPath: "/test", Content: `{{param "id"}}`})
+		So(err, ShouldBeNil)
+		So(p.TemplateExecuteFuncs(ioutil.Discard, nil, template.FuncMap{"param": func() string { return "" }}), ShouldBeError, "failed to execute template due to invalid function param")
+	})
+
+	Convey("should execute page template concurrently with the given functions", t, func() {
+		p, err := page.New(page.Options{URLPath: "/docs/{slug}", Content: `{{param "slug"}}`})
+		So(err, ShouldBeNil)
+
+		results := make(chan bool, 50)
+		for i := 0; i < cap(results); i++ {
+			go func(slug string) {
+				b := bytes.Buffer{}
+				err := p.TemplateExecuteFuncs(&b, nil, page.ParamFuncs(map[string]string{"slug": slug}))
+				results <- err == nil && b.String() == slug
+			}(fmt.Sprint(i))
+		}
+		for i := 0; i < cap(results); i++ {
+			So(<-results, ShouldBeTrue)
+		}
+	})
+}
+
+func TestRequestFuncs(t *testing.T) {
+	Convey("should execute page template with the request functions", t, func() {
+		p, err := page.New(page.Options{URLPath: "/docs/{slug}", Content: `{{url "/docs"}} {{param "slug"}}`})
+		So(err, ShouldBeNil)
+
+		b := bytes.Buffer{}
+		So(p.TemplateExecute(&b, nil), ShouldBeNil)
+		So(b.String(), ShouldEqual, "/docs ")
+
+		r := httptest.NewRequest("GET", "http://localhost/app/docs/foo", nil)
+		ctx := context.WithValue(r.Context(), request.ContextKeys.PathPrefix, "/app")
+		ctx = context.WithValue(ctx, request.ContextKeys.Params, map[string]string{"slug": "foo"})
+		b.Reset()
+		So(p.TemplateExecuteFuncs(&b, nil, page.RequestFuncs(request.New(request.Options{Request: r.WithContext(ctx)}))), ShouldBeNil)
+		So(b.String(), ShouldEqual, "/app/docs foo")
+	})
+}
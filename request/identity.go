@@ -0,0 +1,50 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package request
+
+import (
+	"crypto/x509"
+)
+
+// ClientIdentity represents the identity of a verified TLS client certificate
+type ClientIdentity struct {
+	Subject        string            `json:"subject,omitempty"`
+	CommonName     string            `json:"commonName,omitempty"`
+	DNSNames       []string          `json:"dnsNames,omitempty"`
+	EmailAddresses []string          `json:"emailAddresses,omitempty"`
+	IPAddresses    []string          `json:"ipAddresses,omitempty"`
+	URIs           []string          `json:"uris,omitempty"`
+	Certificate    *x509.Certificate `json:"-"`
+}
+
+// NewClientIdentity returns the client identity of the given certificate
+func NewClientIdentity(cert *x509.Certificate) *ClientIdentity {
+	ci := ClientIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Certificate:    cert,
+	}
+	for _, v := range cert.IPAddresses {
+		ci.IPAddresses = append(ci.IPAddresses, v.String())
+	}
+	for _, v := range cert.URIs {
+		ci.URIs = append(ci.URIs, v.String())
+	}
+
+	return &ci
+}
+
+// Names returns the subject, the common name and the subject alternative names of the client identity
+func (ci *ClientIdentity) Names() []string {
+	result := []string{ci.Subject, ci.CommonName}
+	result = append(result, ci.DNSNames...)
+	result = append(result, ci.EmailAddresses...)
+	result = append(result, ci.IPAddresses...)
+	result = append(result, ci.URIs...)
+	return result
+}
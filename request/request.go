@@ -8,44 +8,41 @@ package request
 
 import (
 	"encoding/json"
-	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
 	"reflect"
 	"strconv"
 	"strings"
+
+	"github.com/devfacet/goweb/log"
 )
 
 type contextKey string
 
+// URLBuilder represents a function that builds the URL path of a named route
+type URLBuilder func(name string, params ...interface{}) (string, error)
+
 const (
 	defaultMaxMemory = 32 << 20 // 32 MB
 )
 
 var (
-	// Logger holds the global logger that can be override by another logger
-	Logger *log.Logger
-
 	// ContextKeys holds request context keys
 	ContextKeys = struct {
-		PathPrefix contextKey
+		PathPrefix     contextKey
+		Params         contextKey
+		ClientIdentity contextKey
+		RouteName      contextKey
+		URLBuilder     contextKey
 	}{
-		PathPrefix: "PathPrefix",
+		PathPrefix:     "PathPrefix",
+		Params:         "Params",
+		ClientIdentity: "ClientIdentity",
+		RouteName:      "RouteName",
+		URLBuilder:     "URLBuilder",
 	}
 )
 
-func init() {
-	// Init logger
-	Logger = log.New(os.Stdout, "", log.LstdFlags)
-	// If it's a test then
-	if flag.Lookup("test.v") != nil {
-		Logger.SetOutput(ioutil.Discard) // discard logs
-	}
-}
-
 // Options represents the options than can be set when creating a new request
 type Options struct {
 	// Request holds the request
@@ -109,6 +106,130 @@ func (request *Request) ContentType() string {
 	return request.contentType
 }
 
+// Params returns the path parameters of the request
+func (request *Request) Params() map[string]string {
+	if request.r != nil {
+		if v, ok := request.r.Context().Value(ContextKeys.Params).(map[string]string); ok {
+			return v
+		}
+	}
+	return map[string]string{}
+}
+
+// Param returns the value of the given path parameter
+func (request *Request) Param(name string) string {
+	return request.Params()[name]
+}
+
+// ParamInt returns the value of the given path parameter as int
+// If the value is not a valid int then it replies the request with a 400 error.
+func (request *Request) ParamInt(name string) (int, error) {
+	v, err := strconv.Atoi(request.Param(name))
+	if err != nil {
+		return 0, request.paramError(name, err)
+	}
+	return v, nil
+}
+
+// ParamInt64 returns the value of the given path parameter as int64
+// If the value is not a valid int64 then it replies the request with a 400 error.
+func (request *Request) ParamInt64(name string) (int64, error) {
+	v, err := strconv.ParseInt(request.Param(name), 10, 64)
+	if err != nil {
+		return 0, request.paramError(name, err)
+	}
+	return v, nil
+}
+
+// ParamFloat64 returns the value of the given path parameter as float64
+// If the value is not a valid float64 then it replies the request with a 400 error.
+func (request *Request) ParamFloat64(name string) (float64, error) {
+	v, err := strconv.ParseFloat(request.Param(name), 64)
+	if err != nil {
+		return 0, request.paramError(name, err)
+	}
+	return v, nil
+}
+
+// ParamBool returns the value of the given path parameter as bool
+// If the value is not a valid bool then it replies the request with a 400 error.
+func (request *Request) ParamBool(name string) (bool, error) {
+	v, err := strconv.ParseBool(request.Param(name))
+	if err != nil {
+		return false, request.paramError(name, err)
+	}
+	return v, nil
+}
+
+// paramError replies the request with a 400 error for the given path parameter and returns the error
+func (request *Request) paramError(name string, err error) error {
+	e := fmt.Errorf("invalid path parameter %s", name)
+	if request.w != nil {
+		request.Reply(Error{
+			StatusCode: http.StatusBadRequest,
+			Message:    e.Error(),
+			Internal:   err,
+		})
+	}
+	return e
+}
+
+// ClientIdentity returns the identity of the verified TLS client certificate
+// It returns nil if the client is not verified.
+func (request *Request) ClientIdentity() *ClientIdentity {
+	if request.r != nil {
+		if v, ok := request.r.Context().Value(ContextKeys.ClientIdentity).(*ClientIdentity); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// PathPrefix returns the path prefix of the request (i.e. "/app")
+// The prefix is set into the request context by the server (see ContextKeys.PathPrefix) and it includes
+// the prefix of a trusted reverse proxy (see server.Options.TrustForwardedPrefix).
+func (request *Request) PathPrefix() string {
+	if request.r != nil {
+		if v, ok := request.r.Context().Value(ContextKeys.PathPrefix).(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// URL returns the URL path of the given path under the path prefix of the request
+// i.e. "users/1" and "/users/1" become "/app/users/1" when the path prefix is "/app".
+func (request *Request) URL(path string) string {
+	return request.PathPrefix() + "/" + strings.TrimLeft(path, "/")
+}
+
+// URLFor returns the URL path of the given named route by the given parameter names and values
+// i.e. `URLFor("user", "id", 1)`. The route names are resolved by the URL builder that is set into
+// the request context (see ContextKeys.URLBuilder).
+func (request *Request) URLFor(name string, params ...interface{}) (string, error) {
+	// Init vars
+	var ub URLBuilder
+
+	if request.r != nil {
+		ub, _ = request.r.Context().Value(ContextKeys.URLBuilder).(URLBuilder)
+	}
+	if ub == nil {
+		return "", fmt.Errorf("failed to build url for %s due to missing url builder", name)
+	}
+
+	return ub(name, params...)
+}
+
+// RouteName returns the name of the matched route (empty if the route is not named)
+func (request *Request) RouteName() string {
+	if request.r != nil {
+		if v, ok := request.r.Context().Value(ContextKeys.RouteName).(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
 // Reply replies an HTTP request
 func (request *Request) Reply(rv interface{}) {
 	// Init vars
@@ -183,7 +304,7 @@ func (request *Request) Reply(rv interface{}) {
 				jsonData = rv
 			} else {
 				// Otherwise
-				Logger.Printf("unknown type: %T/%s/%s", rv, reflect.ValueOf(rv).Kind().String(), reflect.Indirect(reflect.ValueOf(rv)).Kind().String())
+				log.Logger.Warn("unknown reply type", "type", fmt.Sprintf("%T", rv), "kind", reflect.ValueOf(rv).Kind().String(), "indirect", reflect.Indirect(reflect.ValueOf(rv)).Kind().String())
 				result = []byte(fmt.Sprintf("%s", rv))
 			}
 		}
@@ -201,7 +322,7 @@ func (request *Request) Reply(rv interface{}) {
 		// Prepare the result
 		var err error
 		if result, err = json.Marshal(jsonData); err != nil {
-			Logger.Printf("failed to reply due to parse error: %s", err.Error())
+			log.Logger.Error("failed to reply due to parse error", "error", err)
 			header = http.StatusInternalServerError
 			result = []byte(fmt.Sprintf(`{"statusCode":%d,"message":"%s"}`, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)))
 		}
@@ -225,7 +346,7 @@ func (request *Request) FormFiles() []FormFile {
 	}
 
 	if err := request.r.ParseMultipartForm(request.maxMemory); err != nil {
-		Logger.Printf("failed to parse multipart form due to %s", err.Error())
+		log.Logger.Warn("failed to parse multipart form", "error", err)
 		return result
 	}
 
@@ -7,6 +7,9 @@ package request_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
 	"io"
@@ -19,10 +22,16 @@ import (
 
 	"os"
 
+	"github.com/devfacet/goweb/log"
 	"github.com/devfacet/goweb/request"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+func init() {
+	// Disable the logs of the tests
+	log.Logger = log.New(log.Options{Handler: log.Discard})
+}
+
 func TestNew(t *testing.T) {
 	Convey("should return a new request", t, func() {
 		req := request.New(request.Options{Request: httptest.NewRequest("GET", "http://localhost", nil)})
@@ -546,3 +555,85 @@ func TestWriteTo(t *testing.T) {
 		So(err, ShouldBeError, fmt.Errorf("failed to write due to open %s: no such file or directory", tf))
 	})
 }
+
+func TestParams(t *testing.T) {
+	Convey("should return the path parameters", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost/users/1", nil)
+		req := request.New(request.Options{Request: r})
+		So(req.Params(), ShouldBeEmpty)
+		So(req.Param("id"), ShouldEqual, "")
+
+		r = r.WithContext(context.WithValue(r.Context(), request.ContextKeys.Params, map[string]string{"id": "1", "ok": "true", "n": "1.5"}))
+		req = request.New(request.Options{Request: r})
+		So(req.Param("id"), ShouldEqual, "1")
+
+		i, err := req.ParamInt("id")
+		So(err, ShouldBeNil)
+		So(i, ShouldEqual, 1)
+
+		i64, err := req.ParamInt64("id")
+		So(err, ShouldBeNil)
+		So(i64, ShouldEqual, 1)
+
+		f, err := req.ParamFloat64("n")
+		So(err, ShouldBeNil)
+		So(f, ShouldEqual, 1.5)
+
+		b, err := req.ParamBool("ok")
+		So(err, ShouldBeNil)
+		So(b, ShouldEqual, true)
+	})
+
+	Convey("should reply with 400 due to invalid path parameter", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost/users/foo", nil)
+		r = r.WithContext(context.WithValue(r.Context(), request.ContextKeys.Params, map[string]string{"id": "foo"}))
+		w := httptest.NewRecorder()
+		req := request.New(request.Options{Request: r, Writer: w})
+
+		_, err := req.ParamInt("id")
+		So(err, ShouldBeError, errors.New("invalid path parameter id"))
+		resp := w.Result()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(resp.StatusCode, ShouldEqual, 400)
+		So(string(b), ShouldEqual, `{"statusCode":400,"message":"invalid path parameter id"}`)
+	})
+}
+
+func TestClientIdentity(t *testing.T) {
+	Convey("should return the client identity", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost", nil)
+		req := request.New(request.Options{Request: r})
+		So(req.ClientIdentity(), ShouldBeNil)
+
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "foo"}, DNSNames: []string{"foo.local"}}
+		r = r.WithContext(context.WithValue(r.Context(), request.ContextKeys.ClientIdentity, request.NewClientIdentity(cert)))
+		req = request.New(request.Options{Request: r})
+		So(req.ClientIdentity(), ShouldNotBeNil)
+		So(req.ClientIdentity().Subject, ShouldEqual, "CN=foo")
+		So(req.ClientIdentity().CommonName, ShouldEqual, "foo")
+		So(req.ClientIdentity().DNSNames[0], ShouldEqual, "foo.local")
+		So(len(req.ClientIdentity().Names()), ShouldEqual, 3)
+	})
+}
+
+func TestURL(t *testing.T) {
+	Convey("should return the URL path under the path prefix", t, func() {
+		r := httptest.NewRequest("GET", "http://localhost/users/1", nil)
+		req := request.New(request.Options{Request: r})
+		So(req.PathPrefix(), ShouldEqual, "")
+		So(req.URL("users/1"), ShouldEqual, "/users/1")
+
+		r = r.WithContext(context.WithValue(r.Context(), request.ContextKeys.PathPrefix, "/app"))
+		req = request.New(request.Options{Request: r})
+		So(req.PathPrefix(), ShouldEqual, "/app")
+		So(req.URL("users/1"), ShouldEqual, "/app/users/1")
+		So(req.URL("/users/"), ShouldEqual, "/app/users/")
+		So(req.URL(""), ShouldEqual, "/app/")
+
+		// The forwarded prefix is set by the server only if it's trusted
+		r.Header.Set("X-Forwarded-Prefix", "/proxy/")
+		req = request.New(request.Options{Request: r})
+		So(req.PathPrefix(), ShouldEqual, "/app")
+		So(req.URL("/users/1"), ShouldEqual, "/app/users/1")
+	})
+}
@@ -0,0 +1,125 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package route
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Overlap represents two routes that match the same paths
+type Overlap struct {
+	// Route holds the route that wins for the overlapping paths
+	Route Route
+	// Other holds the route that loses for the overlapping paths
+	Other Route
+	// Shadowed indicates whether the routes are equally specific so one of them is never matched
+	Shadowed bool
+	// Example holds an example path that is matched by both routes
+	Example string
+}
+
+// String returns the description of the overlap
+func (o Overlap) String() string {
+	if o.Shadowed {
+		return fmt.Sprintf("route %s shadows %s (i.e. %s)", o.Route.Pattern(), o.Other.Pattern(), o.Example)
+	}
+	return fmt.Sprintf("route %s overlaps %s and wins (i.e. %s)", o.Route.Pattern(), o.Other.Pattern(), o.Example)
+}
+
+// overlapPath returns an example path that is matched by both of the given segments
+func overlapPath(a, b []Segment) (string, bool) {
+	// Init vars
+	parts := []string{}
+
+	for i := 0; ; i++ {
+		switch {
+		case i >= len(a) && i >= len(b):
+			return "/" + strings.Join(parts, "/"), true
+		case i >= len(a) || i >= len(b):
+			return "", false
+		}
+		sa, sb := a[i], b[i]
+		switch {
+		case sa.Kind == SegmentWildcard && sb.Kind == SegmentWildcard:
+			return "/" + strings.Join(append(parts, ""), "/"), true
+		case sa.Kind == SegmentWildcard:
+			a = append(a[:i:i], b[i:]...)
+			sa = a[i]
+		case sb.Kind == SegmentWildcard:
+			b = append(b[:i:i], a[i:]...)
+			sb = b[i]
+		}
+		switch {
+		case sa.Kind == SegmentStatic && sb.Kind == SegmentStatic:
+			if sa.Value != sb.Value {
+				return "", false
+			}
+			parts = append(parts, sa.Value)
+		case sa.Kind == SegmentStatic:
+			parts = append(parts, sa.Value)
+		case sb.Kind == SegmentStatic:
+			parts = append(parts, sb.Value)
+		default:
+			parts = append(parts, "x")
+		}
+	}
+}
+
+// matchMethods returns whether the given method lists have a common method (empty means any method)
+func matchMethods(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, v := range a {
+		for _, vv := range b {
+			if v == vv {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Overlaps returns the routes that match the same paths
+// Redirect routes, the routes with invalid patterns and the routes of the different hosts or methods are ignored.
+func Overlaps(routes []Route) []Overlap {
+	// Init vars
+	result := []Overlap{}
+	segs := make([][]Segment, len(routes))
+	for i, v := range routes {
+		segs[i], _ = ParsePattern(v.Pattern())
+	}
+
+	// Iterate over the route pairs
+	for i := 0; i < len(routes); i++ {
+		for j := i + 1; j < len(routes); j++ {
+			ri, rj := routes[i], routes[j]
+			if ri.Redirect() || rj.Redirect() || segs[i] == nil || segs[j] == nil || ri.Host() != rj.Host() || !matchMethods(ri.Methods(), rj.Methods()) {
+				continue
+			}
+			example, ok := overlapPath(segs[i], segs[j])
+			if !ok {
+				continue
+			}
+			o := Overlap{Route: ri, Other: rj, Example: example}
+			switch d := CompareSegments(segs[i], segs[j]); {
+			case ri.Pattern() == rj.Pattern():
+				// The route of the method wins over the route of any method
+				if len(ri.Methods()) == 0 {
+					o.Route, o.Other = rj, ri
+				}
+			case d == 0:
+				o.Shadowed = true
+			case d < 0:
+				o.Route, o.Other = rj, ri
+			}
+			result = append(result, o)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,59 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package route_test
+
+import (
+	"testing"
+
+	"github.com/devfacet/goweb/route"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOverlaps(t *testing.T) {
+	Convey("should return the overlapping routes", t, func() {
+		routes := []route.Route{
+			*route.New(route.Options{Path: "/bar/", Kind: route.KindPage}),
+			*route.New(route.Options{Path: "/bar", Pattern: "/bar/", Kind: route.KindRedirect, Implicit: true}),
+			*route.New(route.Options{Path: "/bar/baz", Methods: []string{"GET"}}),
+			*route.New(route.Options{Path: "/users/{id}", Methods: []string{"GET"}}),
+			*route.New(route.Options{Path: "/users/new", Methods: []string{"POST"}}),
+			*route.New(route.Options{Path: "/files/", Methods: []string{"GET"}}),
+			*route.New(route.Options{Path: "/files/{path...}", Methods: []string{"GET"}}),
+			*route.New(route.Options{Path: "/bar/baz", Host: "example.local"}),
+		}
+
+		ol := route.Overlaps(routes)
+		So(len(ol), ShouldEqual, 2)
+		So(ol[0].Route.Path(), ShouldEqual, "/bar/baz")
+		So(ol[0].Other.Path(), ShouldEqual, "/bar/")
+		So(ol[0].Shadowed, ShouldBeFalse)
+		So(ol[0].Example, ShouldEqual, "/bar/baz")
+		So(ol[0].String(), ShouldEqual, "route /bar/baz overlaps /bar/ and wins (i.e. /bar/baz)")
+		So(ol[1].Route.Path(), ShouldEqual, "/files/")
+		So(ol[1].Other.Path(), ShouldEqual, "/files/{path...}")
+		So(ol[1].Shadowed, ShouldBeTrue)
+		So(ol[1].Example, ShouldEqual, "/files/")
+		So(ol[1].String(), ShouldEqual, "route /files/ shadows /files/{path...} (i.e. /files/)")
+	})
+
+	Convey("should return the overlapping routes with parameters", t, func() {
+		routes := []route.Route{
+			*route.New(route.Options{Path: "/{lang}/docs/{page}"}),
+			*route.New(route.Options{Path: "/en/{section}/intro"}),
+			*route.New(route.Options{Path: "/en/docs", Methods: []string{"GET"}}),
+			*route.New(route.Options{Path: "/en/docs", Methods: []string{"POST"}}),
+			*route.New(route.Options{Path: "/en/docs"}),
+		}
+
+		ol := route.Overlaps(routes)
+		So(len(ol), ShouldEqual, 3)
+		So(ol[0].Route.Path(), ShouldEqual, "/en/{section}/intro")
+		So(ol[0].Example, ShouldEqual, "/en/docs/intro")
+		So(ol[1].Route.Methods(), ShouldResemble, []string{"GET"})
+		So(ol[1].Other.Methods(), ShouldBeEmpty)
+		So(ol[2].Route.Methods(), ShouldResemble, []string{"POST"})
+	})
+}
@@ -0,0 +1,99 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package route
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SegmentKind represents the kind of a pattern segment
+type SegmentKind int
+
+const (
+	// SegmentStatic represents a static segment (i.e. /users)
+	SegmentStatic SegmentKind = iota + 1
+	// SegmentParam represents a named segment (i.e. /{id})
+	SegmentParam
+	// SegmentWildcard represents a segment that matches the rest of the path (i.e. /{path...} or a trailing slash)
+	SegmentWildcard
+)
+
+// Segment represents a pattern segment
+type Segment struct {
+	// Kind holds the kind of the segment
+	Kind SegmentKind
+	// Value holds the path segment of a static segment or the name of a named segment
+	// It's empty for the wildcard of a trailing slash.
+	Value string
+}
+
+// rank returns the rank of the segment (static segments are more specific than parameters and wildcards)
+func (s Segment) rank() int {
+	switch s.Kind {
+	case SegmentStatic:
+		return 3
+	case SegmentParam:
+		return 2
+	}
+	return 1
+}
+
+// ParsePattern parses the given path pattern into segments
+// Supported segments are static (/foo), named (/{id}) and wildcard (/{path...}).
+// A trailing slash matches the subtree like http.ServeMux does.
+func ParsePattern(pattern string) ([]Segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("invalid pattern %s", pattern)
+	}
+
+	// Iterate over the path segments
+	result := []Segment{}
+	names := map[string]bool{}
+	parts := strings.Split(pattern[1:], "/")
+	for i, v := range parts {
+		last := i == len(parts)-1
+		switch {
+		case v == "" && last:
+			// Subtree
+			result = append(result, Segment{Kind: SegmentWildcard})
+		case strings.HasPrefix(v, "{") && strings.HasSuffix(v, "}"):
+			s := Segment{Kind: SegmentParam, Value: v[1 : len(v)-1]}
+			if strings.HasSuffix(s.Value, "...") {
+				if !last {
+					return nil, fmt.Errorf("invalid pattern %s due to wildcard segment is not at the end", pattern)
+				}
+				s.Kind = SegmentWildcard
+				s.Value = strings.TrimSuffix(s.Value, "...")
+			}
+			if s.Value == "" || strings.ContainsAny(s.Value, "{}.") {
+				return nil, fmt.Errorf("invalid pattern %s due to invalid segment name %s", pattern, v)
+			}
+			if names[s.Value] {
+				return nil, fmt.Errorf("invalid pattern %s due to duplicate segment name %s", pattern, s.Value)
+			}
+			names[s.Value] = true
+			result = append(result, s)
+		case strings.ContainsAny(v, "{}"):
+			return nil, fmt.Errorf("invalid pattern %s due to invalid segment %s", pattern, v)
+		default:
+			result = append(result, Segment{Kind: SegmentStatic, Value: v})
+		}
+	}
+
+	return result, nil
+}
+
+// CompareSegments compares the specificity of the given segments
+// The result is positive if a is more specific than b, negative if it's less and zero if they are equal.
+func CompareSegments(a, b []Segment) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if d := a[i].rank() - b[i].rank(); d != 0 {
+			return d
+		}
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,64 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package route_test
+
+import (
+	"testing"
+
+	"github.com/devfacet/goweb/route"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParsePattern(t *testing.T) {
+	Convey("should parse the patterns into segments", t, func() {
+		segs, err := route.ParsePattern("/users/{id}/files/{path...}")
+		So(err, ShouldBeNil)
+		So(segs, ShouldResemble, []route.Segment{
+			{Kind: route.SegmentStatic, Value: "users"},
+			{Kind: route.SegmentParam, Value: "id"},
+			{Kind: route.SegmentStatic, Value: "files"},
+			{Kind: route.SegmentWildcard, Value: "path"},
+		})
+
+		segs, err = route.ParsePattern("/docs/")
+		So(err, ShouldBeNil)
+		So(segs, ShouldResemble, []route.Segment{
+			{Kind: route.SegmentStatic, Value: "docs"},
+			{Kind: route.SegmentWildcard},
+		})
+	})
+
+	Convey("should fail to parse the invalid patterns", t, func() {
+		for _, v := range []struct {
+			pattern string
+			err     string
+		}{
+			{"docs", "invalid pattern docs"},
+			{"/{path...}/foo", "invalid pattern /{path...}/foo due to wildcard segment is not at the end"},
+			{"/{}", "invalid pattern /{} due to invalid segment name {}"},
+			{"/{id}/{id}", "invalid pattern /{id}/{id} due to duplicate segment name id"},
+			{"/a{id}", "invalid pattern /a{id} due to invalid segment a{id}"},
+		} {
+			_, err := route.ParsePattern(v.pattern)
+			So(err, ShouldBeError, v.err)
+		}
+	})
+}
+
+func TestCompareSegments(t *testing.T) {
+	Convey("should compare the specificity of the segments", t, func() {
+		parse := func(pattern string) []route.Segment {
+			segs, err := route.ParsePattern(pattern)
+			So(err, ShouldBeNil)
+			return segs
+		}
+
+		So(route.CompareSegments(parse("/users/new"), parse("/users/{id}")), ShouldBeGreaterThan, 0)
+		So(route.CompareSegments(parse("/users/{id}"), parse("/users/")), ShouldBeGreaterThan, 0)
+		So(route.CompareSegments(parse("/files/"), parse("/files/{path...}")), ShouldEqual, 0)
+		So(route.CompareSegments(parse("/users/"), parse("/users/{id}/posts")), ShouldBeLessThan, 0)
+	})
+}
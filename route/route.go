@@ -6,28 +6,64 @@
 // Package route provides functions for HTTP routes
 package route
 
-import (
-	"net/http"
-	"reflect"
-	"sort"
-	"strings"
+// Kind represents the kind of a route
+type Kind string
+
+const (
+	// KindHandler represents a route served by a handler
+	KindHandler Kind = "handler"
+	// KindPage represents a route served by a page
+	KindPage Kind = "page"
+	// KindStatic represents a route served from static files
+	KindStatic Kind = "static"
+	// KindRedirect represents a route that redirects to another route
+	KindRedirect Kind = "redirect"
 )
 
 // Options represents the options than can be set when creating a new route
 type Options struct {
+	// Name holds the name of the route (optional)
+	Name string
 	// Path holds path value
 	Path string
+	// Host holds the host pattern (empty means any host)
+	Host string
+	// Pattern holds the pattern value (defaults to path)
+	Pattern string
+	// Methods holds the HTTP methods (empty means any method)
+	Methods []string
+	// Kind holds the kind of the route (defaults to handler)
+	Kind Kind
+	// Prefix holds the path prefix the route is registered under
+	Prefix string
+	// Implicit indicates whether the route is created implicitly or not
+	Implicit bool
+	// Middleware holds the names of the middleware in the order they are invoked
+	Middleware []string
 }
 
 // New returns a route by the given options
 func New(o Options) *Route {
 	// Init the route
 	route := Route{
-		isInit:   true,
-		path:     o.Path,
-		pattern:  o.Path,
-		explicit: true,
-		redirect: false,
+		isInit:     true,
+		name:       o.Name,
+		path:       o.Path,
+		host:       o.Host,
+		pattern:    o.Pattern,
+		methods:    o.Methods,
+		kind:       o.Kind,
+		prefix:     o.Prefix,
+		explicit:   !o.Implicit,
+		middleware: o.Middleware,
+	}
+
+	if route.pattern == "" {
+		route.pattern = route.path
+	}
+
+	if route.kind == "" {
+		route.kind = KindHandler
 	}
 
 	return &route
@@ -35,11 +71,21 @@ func New(o Options) *Route {
 
 // Route represents an HTTP route
 type Route struct {
-	isInit   bool
-	path     string
-	pattern  string
-	explicit bool
-	redirect bool
+	isInit     bool
+	name       string
+	path       string
+	host       string
+	pattern    string
+	methods    []string
+	kind       Kind
+	prefix     string
+	explicit   bool
+	middleware []string
+}
+
+// Name returns the route name
+func (route *Route) Name() string {
+	return route.name
 }
 
 // Path returns the route path
@@ -47,11 +93,33 @@ func (route *Route) Path() string {
 	return route.path
 }
 
+// Host returns the host pattern of the route
+// An empty host means the route is served for the hosts that don't match any other host.
+func (route *Route) Host() string {
+	return route.host
+}
+
 // Pattern returns the pattern value
 func (route *Route) Pattern() string {
 	return route.pattern
 }
 
+// Methods returns the HTTP methods of the route
+// An empty list means the route matches any method.
+func (route *Route) Methods() []string {
+	return route.methods
+}
+
+// Kind returns the kind of the route
+func (route *Route) Kind() Kind {
+	return route.kind
+}
+
+// Prefix returns the path prefix of the route
+func (route *Route) Prefix() string {
+	return route.prefix
+}
+
 // Explicit returns the explicit value
 func (route *Route) Explicit() bool {
 	return route.explicit
@@ -59,7 +127,12 @@ func (route *Route) Explicit() bool {
 
 // Redirect returns the redirect value
 func (route *Route) Redirect() bool {
-	return route.redirect
+	return route.kind == KindRedirect
+}
+
+// Middleware returns the names of the route middleware in the order they are invoked
+func (route *Route) Middleware() []string {
+	return route.middleware
 }
 
 // ByRoutePath implements sort.Interface for []Route
@@ -68,32 +141,3 @@ type ByRoutePath []Route
 func (r ByRoutePath) Len() int           { return len(r) }
 func (r ByRoutePath) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
 func (r ByRoutePath) Less(i, j int) bool { return r[i].Path() < r[j].Path() }
-
-// ListByMux returns the route list by the given ServeMux
-func ListByMux(mux *http.ServeMux) []Route {
-	result := []Route{}
-
-	// Iterate over routes
-	e := reflect.ValueOf(mux).Elem().FieldByName("m")
-	for _, v := range e.MapKeys() {
-		//fmt.Printf("%s > %#v\n", v, e.MapIndex(v)) // for debug
-		rh := false
-		h := e.MapIndex(v).FieldByName("h").Elem()
-		if strings.Contains(reflect.Indirect(h).String(), "http.redirectHandler") {
-			rh = true
-		} else {
-			rh = false
-		}
-		r := Route{
-			path:     v.String(),
-			pattern:  e.MapIndex(v).FieldByName("pattern").String(),
-			explicit: e.MapIndex(v).FieldByName("explicit").Bool(),
-			redirect: rh,
-		}
-		result = append(result, r)
-	}
-
-	sort.Sort(ByRoutePath(result))
-
-	return result
-}
@@ -6,7 +6,6 @@
 package route_test
 
 import (
-	"net/http"
 	"sort"
 	"testing"
 
@@ -24,6 +23,24 @@ func TestNew(t *testing.T) {
 	})
 }
 
+func TestName(t *testing.T) {
+	Convey("should return the given name", t, func() {
+		r := route.New(route.Options{Path: "/test"})
+		So(r.Name(), ShouldEqual, "")
+		r = route.New(route.Options{Name: "test", Path: "/test"})
+		So(r.Name(), ShouldEqual, "test")
+	})
+}
+
+func TestHost(t *testing.T) {
+	Convey("should return the given host", t, func() {
+		r := route.New(route.Options{Path: "/test"})
+		So(r.Host(), ShouldEqual, "")
+		r = route.New(route.Options{Path: "/test", Host: "{tenant}.example.local"})
+		So(r.Host(), ShouldEqual, "{tenant}.example.local")
+	})
+}
+
 func TestPath(t *testing.T) {
 	Convey("should return the given path value", t, func() {
 		r := route.New(route.Options{Path: "/test"})
@@ -49,6 +66,37 @@ func TestRedirect(t *testing.T) {
 	Convey("should return the correct redirect value", t, func() {
 		r := route.New(route.Options{Path: "/test"})
 		So(r.Redirect(), ShouldEqual, false)
+
+		r = route.New(route.Options{Path: "/test", Pattern: "/test/", Kind: route.KindRedirect, Implicit: true})
+		So(r.Redirect(), ShouldEqual, true)
+		So(r.Explicit(), ShouldEqual, false)
+	})
+}
+
+func TestMethods(t *testing.T) {
+	Convey("should return the given methods", t, func() {
+		r := route.New(route.Options{Path: "/test"})
+		So(r.Methods(), ShouldBeEmpty)
+
+		r = route.New(route.Options{Path: "/test", Methods: []string{"GET"}})
+		So(r.Methods()[0], ShouldEqual, "GET")
+	})
+}
+
+func TestKind(t *testing.T) {
+	Convey("should return the correct kind", t, func() {
+		r := route.New(route.Options{Path: "/test"})
+		So(r.Kind(), ShouldEqual, route.KindHandler)
+
+		r = route.New(route.Options{Path: "/test", Kind: route.KindPage})
+		So(r.Kind(), ShouldEqual, route.KindPage)
+	})
+}
+
+func TestPrefix(t *testing.T) {
+	Convey("should return the given prefix", t, func() {
+		r := route.New(route.Options{Path: "/foo/test", Prefix: "/foo/"})
+		So(r.Prefix(), ShouldEqual, "/foo/")
 	})
 }
 
@@ -61,24 +109,13 @@ func TestByRoutePath(t *testing.T) {
 	})
 }
 
-func TestListByMux(t *testing.T) {
-	Convey("should return the list of routes by the given mux", t, func() {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/test", func(http.ResponseWriter, *http.Request) {})
-		mux.HandleFunc("/", func(http.ResponseWriter, *http.Request) {})
-		rl := route.ListByMux(mux)
-		sort.Sort(route.ByRoutePath(rl))
-		So(rl[0].Path(), ShouldEqual, "")
-		So(rl[0].Explicit(), ShouldEqual, false)
-		So(rl[0].Pattern(), ShouldEqual, "/")
-		So(rl[0].Redirect(), ShouldEqual, true)
-		So(rl[1].Path(), ShouldEqual, "/")
-		So(rl[1].Pattern(), ShouldEqual, "/")
-		So(rl[1].Explicit(), ShouldEqual, true)
-		So(rl[1].Redirect(), ShouldEqual, false)
-		So(rl[2].Path(), ShouldEqual, "/test")
-		So(rl[2].Pattern(), ShouldEqual, "/test")
-		So(rl[2].Explicit(), ShouldEqual, true)
-		So(rl[2].Redirect(), ShouldEqual, false)
+func TestMiddleware(t *testing.T) {
+	Convey("should return the given middleware names", t, func() {
+		r := route.New(route.Options{Path: "/test"})
+		So(r.Middleware(), ShouldBeEmpty)
+
+		r = route.New(route.Options{Path: "/test", Middleware: []string{"foo", "bar"}})
+		So(r.Middleware()[0], ShouldEqual, "foo")
+		So(r.Middleware()[1], ShouldEqual, "bar")
 	})
 }
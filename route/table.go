@@ -0,0 +1,115 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Format represents the format of a route table
+type Format string
+
+const (
+	// FormatJSON represents a JSON array of routes
+	FormatJSON Format = "json"
+	// FormatText represents a plain text table with aligned columns
+	FormatText Format = "text"
+	// FormatMarkdown represents a Markdown table
+	FormatMarkdown Format = "markdown"
+)
+
+// tableHeader holds the column names of the route tables
+var tableHeader = []string{"METHOD", "PATTERN", "NAME", "KIND", "MIDDLEWARE"}
+
+// MarshalJSON implements json.Marshaler
+func (route Route) MarshalJSON() ([]byte, error) {
+	methods, mw := route.methods, route.middleware
+	if methods == nil {
+		methods = []string{}
+	}
+	if mw == nil {
+		mw = []string{}
+	}
+
+	// Redirect routes are shown by their paths and the patterns they redirect to
+	pattern, redirect := route.pattern, ""
+	if route.Redirect() {
+		pattern, redirect = route.path, route.pattern
+	}
+
+	return json.Marshal(struct {
+		Methods    []string `json:"methods"`
+		Host       string   `json:"host,omitempty"`
+		Pattern    string   `json:"pattern"`
+		Redirect   string   `json:"redirect,omitempty"`
+		Name       string   `json:"name,omitempty"`
+		Kind       Kind     `json:"kind"`
+		Middleware []string `json:"middleware"`
+	}{methods, route.host, pattern, redirect, route.name, route.kind, mw})
+}
+
+// row returns the column values of the route for the route tables
+func (route *Route) row() []string {
+	// Init vars
+	method, pattern, name, mw := "ANY", route.pattern, "-", "-"
+	if len(route.methods) > 0 {
+		method = strings.Join(route.methods, ",")
+	}
+	if route.Redirect() {
+		pattern = route.path
+	}
+	if route.name != "" {
+		name = route.name
+	}
+	if len(route.middleware) > 0 {
+		mw = strings.Join(route.middleware, ", ")
+	}
+
+	return []string{method, route.host + pattern, name, string(route.kind), mw}
+}
+
+// WriteTable writes the given routes to the given writer in the given format
+// Routes are written in the given order (see Server.Routes), routes of any method are
+// shown as ANY, the host routes are shown with their host patterns (i.e. "api.example.local/users")
+// and the redirect routes are shown by their paths.
+func WriteTable(w io.Writer, routes []Route, f Format) error {
+	switch f {
+	case FormatJSON:
+		if routes == nil {
+			routes = []Route{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(routes)
+	case FormatText:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(tableHeader, "\t"))
+		for _, v := range routes {
+			fmt.Fprintln(tw, strings.Join(v.row(), "\t"))
+		}
+		return tw.Flush()
+	case FormatMarkdown:
+		var b strings.Builder
+		b.WriteString("| Method | Pattern | Name | Kind | Middleware |\n")
+		b.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, v := range routes {
+			row := v.row()
+			for i, c := range row {
+				row[i] = strings.ReplaceAll(c, "|", `\|`)
+			}
+			row[1] = "`" + row[1] + "`"
+			b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	return fmt.Errorf("unknown format %s", f)
+}
@@ -0,0 +1,93 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package route_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/devfacet/goweb/route"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteTable(t *testing.T) {
+	routes := []route.Route{
+		*route.New(route.Options{Path: "/", Kind: route.KindPage, Middleware: []string{"server.Timeout.func1"}}),
+		*route.New(route.Options{Name: "user", Path: "/users/{id}", Methods: []string{"GET", "HEAD"}}),
+		*route.New(route.Options{Path: "/metrics", Host: "metrics.example.local", Methods: []string{"GET"}}),
+		*route.New(route.Options{Path: "/docs", Pattern: "/docs/", Kind: route.KindRedirect, Implicit: true}),
+	}
+
+	Convey("should write the routes as JSON", t, func() {
+		var b strings.Builder
+		So(route.WriteTable(&b, routes[1:], route.FormatJSON), ShouldBeNil)
+		So(b.String(), ShouldEqual, `[
+  {
+    "methods": [
+      "GET",
+      "HEAD"
+    ],
+    "pattern": "/users/{id}",
+    "name": "user",
+    "kind": "handler",
+    "middleware": []
+  },
+  {
+    "methods": [
+      "GET"
+    ],
+    "host": "metrics.example.local",
+    "pattern": "/metrics",
+    "kind": "handler",
+    "middleware": []
+  },
+  {
+    "methods": [],
+    "pattern": "/docs",
+    "redirect": "/docs/",
+    "kind": "redirect",
+    "middleware": []
+  }
+]
+`)
+
+		b.Reset()
+		So(route.WriteTable(&b, nil, route.FormatJSON), ShouldBeNil)
+		So(b.String(), ShouldEqual, "[]\n")
+	})
+
+	Convey("should write the routes as a text table", t, func() {
+		var b strings.Builder
+		So(route.WriteTable(&b, routes, route.FormatText), ShouldBeNil)
+		So(b.String(), ShouldEqual, strings.Join([]string{
+			"METHOD    PATTERN                        NAME  KIND      MIDDLEWARE",
+			"ANY       /                              -     page      server.Timeout.func1",
+			"GET,HEAD  /users/{id}                    user  handler   -",
+			"GET       metrics.example.local/metrics  -     handler   -",
+			"ANY       /docs                          -     redirect  -",
+			"",
+		}, "\n"))
+	})
+
+	Convey("should write the routes as a Markdown table", t, func() {
+		var b strings.Builder
+		So(route.WriteTable(&b, routes[:2], route.FormatMarkdown), ShouldBeNil)
+		So(b.String(), ShouldEqual, strings.Join([]string{
+			"| Method | Pattern | Name | Kind | Middleware |",
+			"| --- | --- | --- | --- | --- |",
+			"| ANY | `/` | - | page | server.Timeout.func1 |",
+			"| GET,HEAD | `/users/{id}` | user | handler | - |",
+			"",
+		}, "\n"))
+	})
+
+	Convey("should fail to write the routes for an unknown format", t, func() {
+		var b strings.Builder
+		err := route.WriteTable(&b, routes, route.Format("xml"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unknown format xml")
+	})
+}
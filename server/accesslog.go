@@ -0,0 +1,225 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRequestIDHeader = "X-Request-ID"
+)
+
+// AccessLogFormat represents the format of the access log lines
+type AccessLogFormat string
+
+const (
+	// AccessLogCommon represents the Common Log Format of Apache
+	// i.e. `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326`
+	AccessLogCommon AccessLogFormat = "common"
+	// AccessLogCombined represents the Combined Log Format of Apache (default)
+	// It's the Common Log Format followed by the quoted referer and user agent.
+	AccessLogCombined AccessLogFormat = "combined"
+	// AccessLogJSON represents a JSON object per line including the duration and the request ID
+	AccessLogJSON AccessLogFormat = "json"
+)
+
+// AccessLogOptions represents the options than can be set when logging requests
+type AccessLogOptions struct {
+	// Writer holds the writer of the access log lines (default os.Stdout)
+	Writer io.Writer
+	// Format holds the format of the access log lines (default AccessLogCombined)
+	Format AccessLogFormat
+	// SkipPaths holds the request paths that are not logged (i.e. "/healthz")
+	SkipPaths []string
+	// Skip returns whether the given request is logged or not (i.e. for skipping health checks by user agent)
+	Skip func(r *http.Request) bool
+	// SampleRate holds the fraction of the requests that are logged between 0 and 1 (i.e. 0.1 for 10%)
+	// Zero means all the requests. Server errors (5xx) are always logged.
+	SampleRate float64
+	// RequestIDHeader holds the header name of the request ID (default "X-Request-ID")
+	// The request ID is taken from the request, or from the response if the request doesn't have one.
+	RequestIDHeader string
+	// Locker holds the lock of the writer (default a lock of the middleware)
+	// The middleware of the servers that share a writer which isn't safe for concurrent use
+	// (i.e. a bytes.Buffer) should share the locker too.
+	Locker sync.Locker
+}
+
+// accessLogEntry represents an access log line in JSON format
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	Duration  float64 `json:"durationMs"`
+	RemoteIP  string  `json:"remoteIp"`
+	User      string  `json:"user,omitempty"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"userAgent,omitempty"`
+	RequestID string  `json:"requestId,omitempty"`
+}
+
+// accessWriter represents a response writer that records the status code and the size of the response
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code
+func (aw *accessWriter) WriteHeader(code int) {
+	if aw.status == 0 && code >= 200 {
+		aw.status = code
+	}
+	aw.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the size of the response
+func (aw *accessWriter) Write(p []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	n, err := aw.ResponseWriter.Write(p)
+	aw.bytes += int64(n)
+	return n, err
+}
+
+// Flush flushes the response (see http.Flusher)
+func (aw *accessWriter) Flush() {
+	http.NewResponseController(aw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying response writer (see http.ResponseController)
+func (aw *accessWriter) Unwrap() http.ResponseWriter {
+	return aw.ResponseWriter
+}
+
+// AccessLog returns a middleware that writes a log line for every request
+// The line contains the method, path, status code, response size, duration, remote IP, user agent
+// and request ID of the request depending on the format (i.e. `Use(server.AccessLog(server.AccessLogOptions{Writer: f}))`).
+// Lines are written by a single write under the lock of the middleware (see AccessLogOptions.Locker),
+// so a writer that is shared by the servers should be safe for concurrent use (i.e. *os.File) or
+// share the locker.
+func AccessLog(o AccessLogOptions) Middleware {
+	// Init vars
+	if o.Writer == nil {
+		o.Writer = os.Stdout
+	}
+	if o.Format == "" {
+		o.Format = AccessLogCombined
+	}
+	if o.RequestIDHeader == "" {
+		o.RequestIDHeader = defaultRequestIDHeader
+	}
+	skipPaths := map[string]bool{}
+	for _, v := range o.SkipPaths {
+		skipPaths[v] = true
+	}
+	if o.Locker == nil {
+		o.Locker = &sync.Mutex{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skipPaths[r.URL.Path] || (o.Skip != nil && o.Skip(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			aw := &accessWriter{ResponseWriter: w}
+			next.ServeHTTP(aw, r)
+			if aw.status == 0 {
+				aw.status = http.StatusOK
+			}
+
+			// Sample the requests except the server errors
+			if o.SampleRate > 0 && o.SampleRate < 1 && aw.status < 500 && rand.Float64() >= o.SampleRate {
+				return
+			}
+
+			line := accessLogLine(o, r, aw, start)
+			o.Locker.Lock()
+			o.Writer.Write(line)
+			o.Locker.Unlock()
+		})
+	}
+}
+
+// accessLogLine returns the access log line of the given request
+func accessLogLine(o AccessLogOptions, r *http.Request, aw *accessWriter, start time.Time) []byte {
+	// Init vars
+	remoteIP := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remoteIP = h
+	}
+	user, _, _ := r.BasicAuth()
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	requestID := r.Header.Get(o.RequestIDHeader)
+	if requestID == "" {
+		requestID = aw.Header().Get(o.RequestIDHeader)
+	}
+
+	if o.Format == AccessLogJSON {
+		b, _ := json.Marshal(accessLogEntry{
+			Time:      start.Format(time.RFC3339Nano),
+			Method:    r.Method,
+			Path:      uri,
+			Proto:     r.Proto,
+			Status:    aw.status,
+			Bytes:     aw.bytes,
+			Duration:  float64(time.Since(start).Microseconds()) / 1000,
+			RemoteIP:  remoteIP,
+			User:      user,
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+			RequestID: requestID,
+		})
+		return append(b, '\n')
+	}
+
+	// Common Log Format
+	if user == "" {
+		user = "-"
+	} else {
+		q := strconv.Quote(user)
+		user = q[1 : len(q)-1]
+	}
+	size := "-"
+	if aw.bytes > 0 {
+		size = strconv.FormatInt(aw.bytes, 10)
+	}
+	line := fmt.Sprintf("%s - %s [%s] %s %d %s", remoteIP, user,
+		start.Format("02/Jan/2006:15:04:05 -0700"), strconv.Quote(r.Method+" "+uri+" "+r.Proto), aw.status, size)
+	if o.Format == AccessLogCombined {
+		line += fmt.Sprintf(" %s %s", accessLogQuote(r.Referer()), accessLogQuote(r.UserAgent()))
+	}
+
+	return []byte(line + "\n")
+}
+
+// accessLogQuote returns the quoted value of the given value ("-" if it's empty)
+func accessLogQuote(v string) string {
+	if v == "" {
+		return `"-"`
+	}
+	return strconv.Quote(v)
+}
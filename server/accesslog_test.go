@@ -0,0 +1,135 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAccessLog(t *testing.T) {
+	// Init vars
+	newServer := func(o server.AccessLogOptions) *server.Server {
+		s := server.New(server.Options{})
+		s.Use(server.AccessLog(o))
+		s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", "res-1")
+			io.WriteString(w, "hello")
+		})
+		s.AddGet("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+		s.AddGet("/error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "error", http.StatusInternalServerError)
+		})
+		return s
+	}
+	newRequest := func(target string) *http.Request {
+		r := httptest.NewRequest("GET", target, nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("User-Agent", `goweb "test"`)
+		r.Header.Set("Referer", "http://example.local/")
+		return r
+	}
+
+	Convey("should write the access log lines in Common Log Format", t, func() {
+		var b bytes.Buffer
+		s := newServer(server.AccessLogOptions{Writer: &b, Format: server.AccessLogCommon})
+		r := newRequest("/users/1?tab=info")
+		r.SetBasicAuth("frank", "secret")
+		s.ServeHTTP(httptest.NewRecorder(), r)
+		So(regexp.MustCompile(`^192\.0\.2\.1 - frank \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /users/1\?tab=info HTTP/1\.1" 200 5\n$`).MatchString(b.String()), ShouldBeTrue)
+
+		b.Reset()
+		s.ServeHTTP(httptest.NewRecorder(), newRequest("/missing"))
+		So(b.String(), ShouldEndWith, `"GET /missing HTTP/1.1" 404 40`+"\n")
+	})
+
+	Convey("should write the access log lines in Combined Log Format", t, func() {
+		var b bytes.Buffer
+		s := newServer(server.AccessLogOptions{Writer: &b})
+		s.ServeHTTP(httptest.NewRecorder(), newRequest("/healthz"))
+		So(regexp.MustCompile(`^192\.0\.2\.1 - - \[.+\] "GET /healthz HTTP/1\.1" 200 - "http://example\.local/" "goweb \\"test\\""\n$`).MatchString(b.String()), ShouldBeTrue)
+	})
+
+	Convey("should write the access log lines in JSON format", t, func() {
+		var b bytes.Buffer
+		s := newServer(server.AccessLogOptions{Writer: &b, Format: server.AccessLogJSON})
+		r := newRequest("/users/1")
+		r.Header.Set("X-Request-ID", "req-1")
+		s.ServeHTTP(httptest.NewRecorder(), r)
+		s.ServeHTTP(httptest.NewRecorder(), newRequest("/users/2"))
+
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		So(len(lines), ShouldEqual, 2)
+		var entry map[string]interface{}
+		So(json.Unmarshal([]byte(lines[0]), &entry), ShouldBeNil)
+		So(entry["method"], ShouldEqual, "GET")
+		So(entry["path"], ShouldEqual, "/users/1")
+		So(entry["status"], ShouldEqual, 200)
+		So(entry["bytes"], ShouldEqual, 5)
+		So(entry["remoteIp"], ShouldEqual, "192.0.2.1")
+		So(entry["userAgent"], ShouldEqual, `goweb "test"`)
+		So(entry["requestId"], ShouldEqual, "req-1")
+		So(entry, ShouldContainKey, "durationMs")
+		So(entry, ShouldContainKey, "time")
+		So(json.Unmarshal([]byte(lines[1]), &entry), ShouldBeNil)
+		So(entry["requestId"], ShouldEqual, "res-1")
+	})
+
+	Convey("should skip and sample the requests", t, func() {
+		var b bytes.Buffer
+		s := newServer(server.AccessLogOptions{
+			Writer:    &b,
+			SkipPaths: []string{"/healthz"},
+			Skip: func(r *http.Request) bool {
+				return r.Header.Get("X-Skip") != ""
+			},
+		})
+		s.ServeHTTP(httptest.NewRecorder(), newRequest("/healthz"))
+		r := newRequest("/users/1")
+		r.Header.Set("X-Skip", "1")
+		s.ServeHTTP(httptest.NewRecorder(), r)
+		So(b.String(), ShouldEqual, "")
+
+		b.Reset()
+		s = newServer(server.AccessLogOptions{Writer: &b, SampleRate: 0.000001})
+		for i := 0; i < 10; i++ {
+			s.ServeHTTP(httptest.NewRecorder(), newRequest("/users/1"))
+		}
+		So(b.String(), ShouldEqual, "")
+		s.ServeHTTP(httptest.NewRecorder(), newRequest("/error"))
+		So(b.String(), ShouldContainSubstring, `"GET /error HTTP/1.1" 500`)
+	})
+	Convey("should share the writer between the servers", t, func() {
+		var b bytes.Buffer
+		var mu sync.Mutex
+		servers := []*server.Server{newServer(server.AccessLogOptions{Writer: &b, Locker: &mu}), newServer(server.AccessLogOptions{Writer: &b, Locker: &mu})}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(s *server.Server) {
+				defer wg.Done()
+				s.ServeHTTP(httptest.NewRecorder(), newRequest("/users/1"))
+			}(servers[i%2])
+		}
+		wg.Wait()
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		So(len(lines), ShouldEqual, 50)
+		for _, v := range lines {
+			So(v, ShouldContainSubstring, `"GET /users/1 HTTP/1.1" 200 5`)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/devfacet/goweb/request"
+)
+
+// clientIdentity sets the identity of the verified client certificate into the request context
+func clientIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			ci := request.NewClientIdentity(r.TLS.VerifiedChains[0][0])
+			r = r.WithContext(context.WithValue(r.Context(), request.ContextKeys.ClientIdentity, ci))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireClientSubject returns a middleware that only allows verified clients with one of the given subjects
+// A subject matches the subject, the common name or one of the subject alternative names of the client certificate.
+// Requests without a verified client certificate are replied with 401 and the rest with 403.
+func RequireClientSubject(subjects ...string) Middleware {
+	// Init vars
+	allowed := map[string]bool{}
+	for _, v := range subjects {
+		allowed[v] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := request.New(request.Options{Request: r, Writer: w})
+			ci := req.ClientIdentity()
+			if ci == nil {
+				req.Reply(request.Error{StatusCode: http.StatusUnauthorized})
+				return
+			}
+			for _, v := range ci.Names() {
+				if v != "" && allowed[v] {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			req.Reply(request.Error{StatusCode: http.StatusForbidden})
+		})
+	}
+}
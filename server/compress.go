@@ -0,0 +1,265 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/devfacet/goweb/content"
+)
+
+const (
+	defaultCompressMinSize = 1 << 10
+)
+
+var (
+	// compressPools holds the writer pools by the encodings and the levels
+	compressPools sync.Map
+)
+
+// CompressOptions represents the options than can be set when compressing responses
+type CompressOptions struct {
+	// Level holds the compression level between 1 (best speed) and 9 (best compression)
+	// Zero or an invalid level means the default level of the compress/gzip package.
+	Level int
+	// MinSize holds the minimum size of the responses to compress (default 1 KB)
+	// A negative value compresses all the responses.
+	MinSize int
+	// Disabled disables the compression (i.e. for a route when the compression is global)
+	Disabled bool
+}
+
+// compressor represents a pooled compression writer
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressPool returns the writer pool of the given encoding and level
+func compressPool(encoding string, level int) *sync.Pool {
+	// Init vars
+	key := struct {
+		encoding string
+		level    int
+	}{encoding, level}
+
+	if v, ok := compressPools.Load(key); ok {
+		return v.(*sync.Pool)
+	}
+	p := &sync.Pool{New: func() interface{} {
+		if encoding == "deflate" {
+			w, _ := flate.NewWriter(io.Discard, level)
+			return w
+		}
+		w, _ := gzip.NewWriterLevel(io.Discard, level)
+		return w
+	}}
+	v, _ := compressPools.LoadOrStore(key, p)
+
+	return v.(*sync.Pool)
+}
+
+// compressWriter represents a response writer that compresses the response by the negotiated encoding
+// The compression is decided when the response reaches the minimum size, is flushed or is finished,
+// so the options can be changed until then (see Compress).
+type compressWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	o       CompressOptions
+	buf     []byte
+	status  int
+	decided bool
+	pool    *sync.Pool
+	cw      compressor
+}
+
+// Compress returns a middleware that compresses the responses by gzip or deflate
+// The encoding is negotiated by the Accept-Encoding header of the request, and the responses that are
+// smaller than the minimum size, already encoded (i.e. precompressed static files), partial or of the
+// content types that are already compressed (see content.Compressible) are sent as they are.
+// The compression can be enabled globally (i.e. `Use(server.Compress(server.CompressOptions{}))`) and
+// overridden per route by another Compress middleware (i.e. `server.Compress(server.CompressOptions{Disabled: true})`).
+func Compress(o CompressOptions) Middleware {
+	if o.Level < flate.HuffmanOnly || o.Level > flate.BestCompression || o.Level == flate.NoCompression {
+		o.Level = gzip.DefaultCompression
+	}
+	if o.MinSize == 0 {
+		o.MinSize = defaultCompressMinSize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Override the options of the outer compression
+			if cw := outerCompressWriter(w); cw != nil {
+				cw.o = o
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, r: r, o: o}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// outerCompressWriter returns the undecided compression writer of an outer Compress middleware
+// The writer can be wrapped by other middleware (i.e. AccessLog) so the Unwrap chain is followed.
+func outerCompressWriter(w http.ResponseWriter) *compressWriter {
+	for {
+		switch v := w.(type) {
+		case *compressWriter:
+			if v.decided {
+				return nil
+			}
+			return v
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// WriteHeader holds the status code until the compression is decided
+func (cw *compressWriter) WriteHeader(code int) {
+	switch {
+	case cw.decided:
+		cw.ResponseWriter.WriteHeader(code)
+	case cw.status != 0:
+		// Superfluous call
+	case code >= 100 && code < 200 && code != http.StatusSwitchingProtocols:
+		// Informational responses are sent as they are (i.e. 103 Early Hints)
+		cw.ResponseWriter.WriteHeader(code)
+	default:
+		cw.status = code
+		if code == http.StatusSwitchingProtocols {
+			cw.decide(false)
+		}
+	}
+}
+
+// Write writes the given data by the compression
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.o.MinSize {
+			return len(p), nil
+		}
+		if err := cw.decide(false); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.cw != nil {
+		return cw.cw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush flushes the compressed data to the client
+// The response is compressed regardless of the minimum size when it's flushed (i.e. streaming responses).
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(true); err != nil {
+			return
+		}
+	}
+	if cw.cw != nil {
+		if err := cw.cw.Flush(); err != nil {
+			return
+		}
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying response writer (see http.ResponseController)
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide decides the encoding, writes the headers and the buffered data
+func (cw *compressWriter) decide(flush bool) error {
+	// Init vars
+	cw.decided = true
+	h := cw.ResponseWriter.Header()
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// Check the response
+	compress := !cw.o.Disabled && cw.r.Method != http.MethodHead && h.Get("Content-Encoding") == "" &&
+		status >= 200 && status != http.StatusNoContent && status != http.StatusPartialContent &&
+		status != http.StatusNotModified && h.Get("Content-Range") == ""
+	encoding := ""
+	if compress {
+		h.Add("Vary", "Accept-Encoding")
+		encoding = content.NegotiateEncoding(cw.r.Header.Get("Accept-Encoding"), "gzip", "deflate")
+		compress = encoding != "" && (flush || (len(cw.buf) > 0 && len(cw.buf) >= cw.o.MinSize))
+	}
+	if compress {
+		ct := h.Get("Content-Type")
+		if ct == "" && len(cw.buf) > 0 {
+			// Detect the content type the same way it would be sniffed if the response wasn't compressed
+			ct = http.DetectContentType(cw.buf)
+			h.Set("Content-Type", ct)
+		}
+		compress = content.Compressible(ct)
+	}
+
+	// Write the headers
+	if compress {
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", encoding)
+		// The compressed representation isn't byte-identical to the original one so a strong ETag is weakened
+		// The weak ETag still matches the original one by the If-None-Match header (see http.ServeContent).
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.pool = compressPool(encoding, cw.o.Level)
+		cw.cw = cw.pool.Get().(compressor)
+		cw.cw.Reset(cw.ResponseWriter)
+	}
+	if cw.status != 0 || len(cw.buf) > 0 || flush {
+		cw.ResponseWriter.WriteHeader(status)
+	}
+
+	// Write the buffered data
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.cw != nil {
+		_, err = cw.cw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+// close decides the compression of the buffered response and finishes the compressed stream
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.cw != nil {
+		cw.cw.Close()
+		cw.cw.Reset(io.Discard)
+		cw.pool.Put(cw.cw)
+		cw.cw = nil
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompress(t *testing.T) {
+	// Init vars
+	large := strings.Repeat(`{"name":"goweb"},`, 128)
+	handler := func(ct, body string) func(http.ResponseWriter, *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if ct != "" {
+				w.Header().Set("Content-Type", ct)
+			}
+			io.WriteString(w, body)
+		}
+	}
+	gzipped := map[string]string{"Accept-Encoding": "gzip, deflate"}
+
+	Convey("should compress the responses by the negotiated encoding", t, func() {
+		s := server.New(server.Options{})
+		s.Use(server.Compress(server.CompressOptions{}))
+		s.AddGet("/json", handler("application/json", large))
+		s.AddGet("/html", handler("", "<html>"+strings.Repeat("<p>goweb</p>", 128)+"</html>"))
+		s.AddGet("/small", handler("application/json", `{}`))
+		s.AddGet("/sniffed", handler("", "\x1f\x8b\x08"+large))
+		s.AddGet("/png", handler("image/png", large))
+		s.AddGet("/encoded", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, large)
+		})
+		s.AddGet("/created", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, large)
+		})
+		s.AddGet("/level", handler("text/plain", large), server.Compress(server.CompressOptions{Level: flate.BestSpeed}))
+		s.AddGet("/disabled", handler("application/json", large), server.Compress(server.CompressOptions{Disabled: true}))
+
+		w := testServe(s, "GET", "/json", gzipped)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+		So(w.Header().Get("Content-Length"), ShouldEqual, "")
+		So(w.Body.Len(), ShouldBeLessThan, len(large))
+		gr, err := gzip.NewReader(w.Body)
+		So(err, ShouldBeNil)
+		b, err := io.ReadAll(gr)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, large)
+
+		w = testServe(s, "GET", "/json", map[string]string{"Accept-Encoding": "deflate"})
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "deflate")
+		b, err = io.ReadAll(flate.NewReader(w.Body))
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, large)
+
+		w = testServe(s, "GET", "/json", nil)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+		So(w.Body.String(), ShouldEqual, large)
+
+		w = testServe(s, "GET", "/html", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+
+		w = testServe(s, "GET", "/sniffed", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/x-gzip")
+		So(w.Body.String(), ShouldEqual, "\x1f\x8b\x08"+large)
+
+		w = testServe(s, "GET", "/small", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Body.String(), ShouldEqual, `{}`)
+
+		w = testServe(s, "GET", "/png", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Body.String(), ShouldEqual, large)
+
+		w = testServe(s, "GET", "/encoded", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "br")
+		So(w.Header().Get("Vary"), ShouldEqual, "")
+		So(w.Body.String(), ShouldEqual, large)
+
+		w = testServe(s, "GET", "/created", gzipped)
+		So(w.Code, ShouldEqual, http.StatusCreated)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+
+		w = testServe(s, "GET", "/level", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		gr, err = gzip.NewReader(w.Body)
+		So(err, ShouldBeNil)
+		b, err = io.ReadAll(gr)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, large)
+
+		w = testServe(s, "GET", "/disabled", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Body.String(), ShouldEqual, large)
+
+		w = testServe(s, "HEAD", "/json", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+	})
+
+	Convey("should override the outer compression through the wrapping middleware", t, func() {
+		s := server.New(server.Options{})
+		s.Use(server.Compress(server.CompressOptions{}), server.AccessLog(server.AccessLogOptions{Writer: io.Discard}))
+		s.AddGet("/json", handler("application/json", large))
+		s.AddGet("/disabled", handler("application/json", large), server.Compress(server.CompressOptions{Disabled: true}))
+
+		w := testServe(s, "GET", "/json", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+
+		w = testServe(s, "GET", "/disabled", gzipped)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Body.String(), ShouldEqual, large)
+	})
+
+	Convey("should flush the compressed data of the streaming responses", t, func() {
+		s := server.New(server.Options{})
+		next := make(chan struct{})
+		s.AddGet("/events", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: 1\n\n")
+			http.NewResponseController(w).Flush()
+			<-next
+			io.WriteString(w, "data: 2\n\n")
+		}, server.Compress(server.CompressOptions{}))
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		req, err := http.NewRequest("GET", ts.URL+"/events", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res, err := http.DefaultTransport.RoundTrip(req)
+		So(err, ShouldBeNil)
+		defer res.Body.Close()
+		So(res.Header.Get("Content-Encoding"), ShouldEqual, "gzip")
+
+		gr, err := gzip.NewReader(res.Body)
+		So(err, ShouldBeNil)
+		b := make([]byte, 9)
+		_, err = io.ReadFull(gr, b)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "data: 1\n\n")
+		close(next)
+		b, err = io.ReadAll(gr)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "data: 2\n\n")
+	})
+	Convey("should weaken the ETags of the compressed static files", t, func() {
+		s := server.New(server.Options{})
+		s.AddStatic("/assets", http.Dir(testStaticDir(t)), server.StaticOptions{}, server.Compress(server.CompressOptions{MinSize: -1}))
+
+		w := testServe(s, "GET", "/assets/app.js", nil)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		etag := w.Header().Get("ETag")
+		So(etag, ShouldStartWith, `"`)
+
+		w = testServe(s, "GET", "/assets/app.js", gzipped)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(w.Header().Get("ETag"), ShouldEqual, "W/"+etag)
+
+		w = testServe(s, "GET", "/assets/app.js", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": "W/" + etag})
+		So(w.Code, ShouldEqual, http.StatusNotModified)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+	})
+}
@@ -0,0 +1,126 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/route"
+)
+
+// endpoint represents a set of handlers registered on the same pattern
+type endpoint struct {
+	pattern  string
+	segments []route.Segment
+	any      http.Handler
+	handlers map[string]http.Handler
+}
+
+// newEndpoint returns a new endpoint by the given pattern
+func newEndpoint(pattern string) *endpoint {
+	return &endpoint{
+		pattern:  pattern,
+		handlers: map[string]http.Handler{},
+	}
+}
+
+// add adds the given handler for the given method
+// An empty method means the handler serves any method.
+func (e *endpoint) add(method string, handler http.Handler) error {
+	if method == "" {
+		if e.any != nil {
+			return fmt.Errorf("multiple registrations for %s", e.pattern)
+		}
+		e.any = handler
+		return nil
+	}
+	if _, ok := e.handlers[method]; ok {
+		return fmt.Errorf("multiple registrations for %s %s", method, e.pattern)
+	}
+	e.handlers[method] = handler
+
+	return nil
+}
+
+// allow returns the allowed methods for the endpoint
+func (e *endpoint) allow() []string {
+	result := []string{}
+	for k := range e.handlers {
+		result = append(result, k)
+	}
+	if _, ok := e.handlers[http.MethodGet]; ok {
+		if _, ok := e.handlers[http.MethodHead]; !ok {
+			result = append(result, http.MethodHead)
+		}
+	}
+	if _, ok := e.handlers[http.MethodOptions]; !ok {
+		result = append(result, http.MethodOptions)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// handler returns the handler of the given request method
+// The requests of the methods without a handler are replied by the allowed methods.
+func (e *endpoint) handler(method string) http.Handler {
+	// If there is a handler for the method then
+	if h, ok := e.handlers[method]; ok {
+		return h
+	}
+
+	// HEAD requests fall back to GET handlers
+	if method == http.MethodHead {
+		if h, ok := e.handlers[http.MethodGet]; ok {
+			return h
+		}
+	}
+
+	// If there is a handler for any method then
+	if e.any != nil {
+		return e.any
+	}
+
+	// Reply OPTIONS requests and reject the rest
+	allow := strings.Join(e.allow(), ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		replyError(w, http.StatusMethodNotAllowed, "")
+	})
+}
+
+// replyError replies by an error of the given status code (see request.Error)
+// All the error responses of the server (i.e. 404, 405 and static file errors) are replied this way.
+// Unlike Request.Reply it never replies JSONP since the callback parameter of an unknown URL
+// would let anyone serve scripts from the origin of the server.
+func replyError(w http.ResponseWriter, code int, message string) {
+	if message == "" {
+		message = http.StatusText(code)
+	}
+	b, _ := json.Marshal(request.Error{StatusCode: code, Message: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// splitPattern splits the given pattern into method and path (i.e. "GET /foo")
+func splitPattern(pattern string) (string, string) {
+	pattern = strings.TrimSpace(pattern)
+	if i := strings.IndexAny(pattern, " \t"); i > 0 {
+		return strings.ToUpper(pattern[:i]), strings.TrimSpace(pattern[i+1:])
+	}
+	return "", pattern
+}
@@ -0,0 +1,218 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// State represents the state of a server
+type State string
+
+const (
+	// StateNew represents a server that is not started yet
+	StateNew State = "new"
+	// StateStarting represents a server that is binding its listener
+	StateStarting State = "starting"
+	// StateServing represents a server that is serving requests
+	StateServing State = "serving"
+	// StateStopped represents a server that is closed or shut down
+	StateStopped State = "stopped"
+	// StateFailed represents a server that is failed to start or serve
+	StateFailed State = "failed"
+)
+
+// NewServerGroup returns a new server group by the given servers
+func NewServerGroup(s ...*Server) *ServerGroup {
+	// Init the server group
+	sg := ServerGroup{
+		servers:  s,
+		restarts: map[*Server]chan error{},
+	}
+
+	return &sg
+}
+
+// ServerGroup represents a group of servers that are started and stopped together
+type ServerGroup struct {
+	servers  []*Server
+	mu       sync.Mutex
+	running  bool
+	restarts map[*Server]chan error
+}
+
+// Servers returns the servers of the group
+func (sg *ServerGroup) Servers() []*Server {
+	return sg.servers
+}
+
+// States returns the states of the servers by their ids
+func (sg *ServerGroup) States() map[string]State {
+	result := map[string]State{}
+	for _, v := range sg.servers {
+		result[v.ID()] = v.State()
+	}
+	return result
+}
+
+// Listen starts all the servers and blocks until all of them are stopped
+// If a server fails or the given context is done then all the servers are shut down.
+// The errors of the failed servers are returned joined.
+func (sg *ServerGroup) Listen(ctx context.Context) error {
+	sg.mu.Lock()
+	if sg.running {
+		sg.mu.Unlock()
+		return errors.New("server group is already listening")
+	}
+	sg.running = true
+	sg.mu.Unlock()
+
+	// Init vars
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, len(sg.servers))
+	wg := sync.WaitGroup{}
+	wg.Add(len(sg.servers))
+
+	// Iterate over the servers
+	for i, v := range sg.servers {
+		go func(i int, v *Server) {
+			defer wg.Done()
+			if err := sg.listen(ctx, v); err != nil {
+				errs[i] = err
+				cancel() // stop the others
+			}
+		}(i, v)
+	}
+
+	// Shut down all when the context is done
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			sg.Shutdown()
+		case <-stopped:
+		}
+	}()
+	wg.Wait()
+	close(stopped)
+
+	sg.mu.Lock()
+	sg.running = false
+	sg.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// Run starts all the servers and blocks until the given context is done or an interrupt/terminate signal is received
+// Then it shuts down all the servers gracefully.
+func (sg *ServerGroup) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return sg.Listen(ctx)
+}
+
+// listen listens the given server until it's stopped and restarts it when it's requested
+func (sg *ServerGroup) listen(ctx context.Context, s *Server) error {
+	var restart chan error
+	for {
+		err := s.Start()
+		if restart != nil {
+			restart <- err
+			restart = nil
+		}
+		if err == nil {
+			// If the group is stopped while starting then shut it down
+			if ctx.Err() != nil {
+				s.Shutdown()
+			}
+			err = s.wait()
+		}
+
+		// If it's a restart request then start it again
+		sg.mu.Lock()
+		restart = sg.restarts[s]
+		delete(sg.restarts, s)
+		sg.mu.Unlock()
+		if restart != nil {
+			if ctx.Err() == nil {
+				continue
+			}
+			restart <- ctx.Err()
+		}
+
+		if err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+}
+
+// Restart shuts down the given server gracefully and starts it again
+// It returns after the server listener is bound again.
+func (sg *ServerGroup) Restart(s *Server) error {
+	sg.mu.Lock()
+	if !sg.running {
+		sg.mu.Unlock()
+		return errors.New("server group is not listening")
+	}
+	found := false
+	for _, v := range sg.servers {
+		if v == s {
+			found = true
+		}
+	}
+	if !found {
+		sg.mu.Unlock()
+		return fmt.Errorf("server %s is not in the group", s.ID())
+	}
+	if _, ok := sg.restarts[s]; ok {
+		sg.mu.Unlock()
+		return fmt.Errorf("server %s is already restarting", s.ID())
+	}
+	c := make(chan error, 1)
+	sg.restarts[s] = c
+	sg.mu.Unlock()
+
+	if err := s.Shutdown(); err != nil {
+		return err
+	}
+	return <-c
+}
+
+// Shutdown gracefully shuts down all the servers concurrently
+// So the slowest server determines the shutdown duration instead of the sum of all.
+func (sg *ServerGroup) Shutdown() error {
+	errs := make([]error, len(sg.servers))
+	wg := sync.WaitGroup{}
+	wg.Add(len(sg.servers))
+	for i, v := range sg.servers {
+		go func(i int, v *Server) {
+			defer wg.Done()
+			errs[i] = v.Shutdown()
+		}(i, v)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Close closes all the servers immediately
+func (sg *ServerGroup) Close() error {
+	errs := []error{}
+	for _, v := range sg.servers {
+		errs = append(errs, v.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServerGroup(t *testing.T) {
+	Convey("should listen, restart and stop the servers", t, func() {
+		web := server.New(server.Options{ID: "web"})
+		web.AddGet("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("web")) })
+		api := server.New(server.Options{ID: "api"})
+		api.AddGet("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("api")) })
+		sg := server.NewServerGroup(web, api)
+		So(len(sg.Servers()), ShouldEqual, 2)
+		So(sg.States()["web"], ShouldEqual, server.StateNew)
+		So(sg.Restart(web), ShouldBeError, "server group is not listening")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() {
+			result <- sg.Listen(ctx)
+		}()
+		<-web.Ready()
+		<-api.Ready()
+		So(sg.States()["web"], ShouldEqual, server.StateServing)
+		So(sg.States()["api"], ShouldEqual, server.StateServing)
+
+		So(sg.Restart(web), ShouldBeNil)
+		<-web.Ready()
+		So(web.State(), ShouldEqual, server.StateServing)
+		resp, err := http.Get(fmt.Sprintf("http://%s/", web.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "web")
+
+		So(sg.Restart(server.New(server.Options{ID: "other"})), ShouldBeError, "server other is not in the group")
+
+		cancel()
+		select {
+		case err := <-result:
+			So(err, ShouldBeNil)
+		case <-time.After(time.Second):
+			So("timeout", ShouldBeNil)
+		}
+		So(sg.States()["web"], ShouldEqual, server.StateStopped)
+		So(sg.States()["api"], ShouldEqual, server.StateStopped)
+	})
+
+	Convey("should stop all the servers and return all the errors", t, func() {
+		s1 := server.New(server.Options{ID: "s1", Address: "localhost"})
+		s2 := server.New(server.Options{ID: "s2", Address: "fd:3"})
+		s3 := server.New(server.Options{ID: "s3"})
+		sg := server.NewServerGroup(s1, s2, s3)
+
+		err := sg.Listen(context.Background())
+		So(err, ShouldBeError, "listen tcp: address localhost: missing port in address\nfile descriptor 3 is not inherited")
+		So(s1.State(), ShouldEqual, server.StateFailed)
+		So(s2.State(), ShouldEqual, server.StateFailed)
+		So(s3.State(), ShouldEqual, server.StateStopped)
+	})
+
+	Convey("should run the servers until the context is done", t, func() {
+		s1 := server.New(server.Options{ID: "s1"})
+		s2 := server.New(server.Options{ID: "s2"})
+		sg := server.NewServerGroup(s1, s2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() {
+			result <- sg.Run(ctx)
+		}()
+		<-s1.Ready()
+		<-s2.Ready()
+		cancel()
+		select {
+		case err := <-result:
+			So(err, ShouldBeNil)
+		case <-time.After(time.Second):
+			So("timeout", ShouldBeNil)
+		}
+		So(s1.State(), ShouldEqual, server.StateStopped)
+		So(s2.State(), ShouldEqual, server.StateStopped)
+	})
+	Convey("should shut down the servers concurrently", t, func() {
+		s1 := server.New(server.Options{ID: "s1"})
+		s2 := server.New(server.Options{ID: "s2"})
+		for _, v := range []*server.Server{s1, s2} {
+			v.OnShutdown(func(context.Context) { time.Sleep(200 * time.Millisecond) })
+		}
+		sg := server.NewServerGroup(s1, s2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() {
+			result <- sg.Listen(ctx)
+		}()
+		<-s1.Ready()
+		<-s2.Ready()
+		start := time.Now()
+		cancel()
+		So(<-result, ShouldBeNil)
+		So(time.Since(start), ShouldBeLessThan, 350*time.Millisecond)
+		So(s1.State(), ShouldEqual, server.StateStopped)
+		So(s2.State(), ShouldEqual, server.StateStopped)
+	})
+}
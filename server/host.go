@@ -0,0 +1,198 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/devfacet/goweb/log"
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/route"
+)
+
+// host represents a set of routes that are served for the matching hosts
+type host struct {
+	pattern string
+	labels  []route.Segment
+	mux     *mux
+}
+
+// parseHost parses the given host pattern into labels (in reverse order)
+// Supported labels are static (example), named ({tenant}) and wildcard (*) which matches
+// one or more labels and can only be the first label (i.e. "*.example.local").
+func parseHost(pattern string) ([]route.Segment, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("invalid host %s", pattern)
+	}
+
+	// Iterate over the labels from right to left
+	result := []route.Segment{}
+	names := map[string]bool{}
+	parts := strings.Split(pattern, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		v := parts[i]
+		switch {
+		case v == "*":
+			if i != 0 {
+				return nil, fmt.Errorf("invalid host %s due to wildcard label is not at the beginning", pattern)
+			}
+			result = append(result, route.Segment{Kind: route.SegmentWildcard})
+		case strings.HasPrefix(v, "{") && strings.HasSuffix(v, "}"):
+			s := route.Segment{Kind: route.SegmentParam, Value: v[1 : len(v)-1]}
+			if s.Value == "" || strings.ContainsAny(s.Value, "{}.*") {
+				return nil, fmt.Errorf("invalid host %s due to invalid label name %s", pattern, v)
+			}
+			if names[s.Value] {
+				return nil, fmt.Errorf("invalid host %s due to duplicate label name %s", pattern, s.Value)
+			}
+			names[s.Value] = true
+			result = append(result, s)
+		case v == "" || strings.ContainsAny(v, "{}*/:"):
+			return nil, fmt.Errorf("invalid host %s due to invalid label %s", pattern, v)
+		default:
+			result = append(result, route.Segment{Kind: route.SegmentStatic, Value: v})
+		}
+	}
+
+	return result, nil
+}
+
+// hostName returns the canonical host name of the given host (lower case, without port)
+func hostName(h string) string {
+	if v, _, err := net.SplitHostPort(h); err == nil {
+		h = v
+	}
+	return strings.TrimSuffix(strings.ToLower(h), ".")
+}
+
+// matchHost matches the given host name against the given labels
+// The labels that are matched by a wildcard are set as the "*" parameter (i.e. "a.b" for "a.b.example.local").
+func matchHost(labels []route.Segment, name string) (map[string]string, bool) {
+	// Init vars
+	var params map[string]string
+	parts := strings.Split(name, ".")
+
+	for i, v := range labels {
+		if i >= len(parts) {
+			return nil, false
+		}
+		p := parts[len(parts)-1-i]
+		switch v.Kind {
+		case route.SegmentStatic:
+			if p != v.Value {
+				return nil, false
+			}
+		case route.SegmentParam:
+			if p == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = map[string]string{}
+			}
+			params[v.Value] = p
+		case route.SegmentWildcard:
+			if p == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = map[string]string{}
+			}
+			params["*"] = strings.Join(parts[:len(parts)-i], ".")
+			return params, true
+		}
+	}
+
+	return params, len(parts) == len(labels)
+}
+
+// Host returns a router for the given host pattern
+// The routes of the router are only served for the matching hosts (i.e. "metrics.example.local").
+// Host labels can be named (i.e. "{tenant}.example.local") which are available as path parameters, and
+// the first label can be a wildcard for any subdomain (i.e. "*.example.local") which is available as
+// the "*" parameter. Exact hosts are preferred over the patterns, and the requests that don't match
+// any route of the matching hosts are served by the routes that are registered without a host.
+// An invalid host pattern is reported by Server.Err and the registrations of its router.
+func (server *Server) Host(pattern string) *Router {
+	// Init vars
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	labels, err := parseHost(pattern)
+
+	server.routesMu.Lock()
+	defer server.routesMu.Unlock()
+
+	// Invalid hosts are reported instead of panicking and the routes of the router are never added (see Err)
+	if err != nil {
+		err = fmt.Errorf("failed to add host due to %s", err.Error())
+		server.routeErrs = append(server.routeErrs, err)
+		log.Logger.Error("failed to add host", "server", server.id, "host", pattern, "error", err)
+		return &Router{server: server, prefix: server.pathRoot, host: pattern, err: err}
+	}
+
+	if _, ok := server.hosts[pattern]; !ok {
+		h := &host{pattern: pattern, labels: labels, mux: newMux(server.matcher)}
+		server.hosts[pattern] = h
+		server.hostList = append(server.hostList, h)
+		// Keep the most specific hosts first
+		sort.SliceStable(server.hostList, func(i, j int) bool {
+			return route.CompareSegments(server.hostList[i].labels, server.hostList[j].labels) > 0
+		})
+	}
+
+	return &Router{server: server, prefix: server.pathRoot, host: pattern}
+}
+
+// routeHost dispatches the request to the mux of the matching host
+// The routes are matched under the read lock so they can be added while serving, and the
+// matching handler is served after releasing it.
+func (server *Server) routeHost(w http.ResponseWriter, r *http.Request) {
+	server.routesMu.RLock()
+	m := server.mux
+	if len(server.hostList) > 0 {
+		if h, params := server.matchHostPath(r.Host, r.URL.Path); h != nil {
+			if len(params) > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), request.ContextKeys.Params, params))
+			}
+			m = h.mux
+		}
+	}
+	handler, r := m.handler(r)
+	server.routesMu.RUnlock()
+
+	handler.ServeHTTP(w, r)
+}
+
+// matchHostPath returns the most specific host that matches the given host and has a route for the given path
+// It returns nil if there isn't any so the routes that are registered without a host are used.
+func (server *Server) matchHostPath(hostport, p string) (*host, map[string]string) {
+	// Init vars
+	name := hostName(hostport)
+	cp := cleanPath(p)
+
+	for _, v := range server.hostList {
+		params, ok := matchHost(v.labels, name)
+		if !ok {
+			continue
+		}
+		if e, _, redirect := v.mux.match(cp); e != nil || redirect != "" {
+			return v, params
+		}
+	}
+
+	return nil, nil
+}
+
+// hostMux returns the mux of the given host pattern
+func (server *Server) hostMux(pattern string) *mux {
+	if pattern == "" {
+		return server.mux
+	}
+	return server.hosts[pattern].mux
+}
@@ -0,0 +1,103 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHost(t *testing.T) {
+	Convey("should route the requests by host", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("default")) })
+		metrics := s.Host("Metrics.Example.Local")
+		metrics.AddGet("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("metrics")) })
+		tenant := s.Host("{tenant}.example.local")
+		tenant.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			req := request.New(request.Options{Request: r})
+			w.Write([]byte(req.Param("tenant") + " " + req.Param("id")))
+		})
+		s.Host("*.apps.local").Group("/api").AddGet("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("apps " + request.New(request.Options{Request: r}).Param("*")))
+		})
+		s.Host("admin.apps.local").AddGet("/status", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("admin")) })
+		So(metrics.Host(), ShouldEqual, "metrics.example.local")
+		So(tenant.Group("/admin").Host(), ShouldEqual, "{tenant}.example.local")
+
+		get := func(host, path string) (int, string) {
+			r := httptest.NewRequest("GET", path, nil)
+			r.Host = host
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			return w.Code, w.Body.String()
+		}
+
+		code, body := get("metrics.example.local:8080", "/")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "metrics")
+		code, body = get("acme.example.local", "/users/1")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "acme 1")
+		code, body = get("acme.example.local", "/")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "default")
+		code, body = get("a.b.apps.local", "/api/")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "apps a.b")
+		code, body = get("admin.apps.local", "/status")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "admin")
+		code, body = get("admin.apps.local", "/api/")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "apps admin")
+		code, body = get("admin.apps.local", "/missing")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "default")
+		m, err := s.Match("GET", "http://acme.example.local/")
+		So(err, ShouldBeNil)
+		So(m.Route.Host(), ShouldEqual, "")
+		m, err = s.Match("GET", "http://a.b.apps.local/api/")
+		So(err, ShouldBeNil)
+		So(m.Route.Host(), ShouldEqual, "*.apps.local")
+		So(m.Params["*"], ShouldEqual, "a.b")
+		code, body = get("apps.local", "/")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "default")
+		code, body = get("a.b.example.local", "/")
+		So(code, ShouldEqual, http.StatusOK)
+		So(body, ShouldEqual, "default")
+
+		hosts := map[string]bool{}
+		for _, v := range s.Routes() {
+			hosts[v.Host()+v.Path()] = true
+		}
+		So(len(s.Routes()), ShouldEqual, 8)
+		So(hosts, ShouldContainKey, "metrics.example.local/")
+		So(hosts, ShouldContainKey, "{tenant}.example.local/users/{id}")
+		So(hosts, ShouldContainKey, "*.apps.local/api/")
+		So(hosts, ShouldContainKey, "*.apps.local/api")
+	})
+
+	Convey("should fail to add invalid hosts", t, func() {
+		s := server.New(server.Options{})
+		reg := s.Host("a.*.local").AddGet("/", func(w http.ResponseWriter, r *http.Request) {})
+		So(reg.Err(), ShouldBeError, "failed to add host due to invalid host a.*.local due to wildcard label is not at the beginning")
+		So(reg.Name("root").Err(), ShouldNotBeNil)
+		s.Host("{a}.{a}.local").Group("/api").AddGet("/", func(w http.ResponseWriter, r *http.Request) {})
+		s.Host("")
+		So(s.Err(), ShouldBeError, "failed to add host due to invalid host a.*.local due to wildcard label is not at the beginning\n"+
+			"failed to add host due to invalid host {a}.{a}.local due to duplicate label name a\n"+
+			"failed to add host due to invalid host ")
+		So(len(s.Routes()), ShouldEqual, 0)
+		So(s.Start(), ShouldNotBeNil)
+	})
+}
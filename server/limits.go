@@ -0,0 +1,98 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultMaxHeaderBytes    = 1 << 20
+)
+
+// limitValue returns the given value, the default value if it's zero or zero if it's negative
+func limitValue(v, def int64) int64 {
+	if v == 0 {
+		return def
+	}
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+// limitedBody represents a request body with a size limit that can be changed until it's read
+type limitedBody struct {
+	w     http.ResponseWriter
+	body  io.ReadCloser
+	limit int64
+	r     io.ReadCloser
+}
+
+// Read reads from the body and returns an *http.MaxBytesError when the limit is exceeded
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.r == nil {
+		if lb.limit > 0 {
+			lb.r = http.MaxBytesReader(lb.w, lb.body, lb.limit)
+		} else {
+			lb.r = lb.body
+		}
+	}
+	return lb.r.Read(p)
+}
+
+// Close closes the body
+func (lb *limitedBody) Close() error {
+	return lb.body.Close()
+}
+
+// bodyLimit limits the request bodies by the given size (zero means no limit)
+func bodyLimit(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &limitedBody{w: w, body: r.Body, limit: limit}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MaxBodySize returns a middleware that overrides the request body size limit of the server
+// It can be used for allowing large uploads on a route (i.e. `server.MaxBodySize(1 << 30)`).
+// A negative size disables the limit.
+func MaxBodySize(size int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if lb, ok := r.Body.(*limitedBody); ok && lb.r == nil {
+				lb.limit = limitValue(size, 0)
+			} else if size > 0 && r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, size)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Timeout returns a middleware that overrides the request deadline of the server
+// It extends (or shortens) the read and write deadlines of the connection and cancels the request
+// context when the deadline is exceeded.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline := time.Now().Add(d)
+			rc := http.NewResponseController(w)
+			rc.SetReadDeadline(deadline)
+			rc.SetWriteDeadline(deadline)
+			ctx, cancel := context.WithDeadline(r.Context(), deadline)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	Convey("should limit the request bodies globally and per route", t, func() {
+		s := server.New(server.Options{MaxBodyBytes: 8})
+		read := func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			w.Write(b)
+		}
+		s.AddPost("/small", read)
+		s.AddPost("/upload", read, server.MaxBodySize(1024))
+		s.AddPost("/unlimited", read, server.MaxBodySize(-1))
+		So(s.Start(), ShouldBeNil)
+		<-s.Ready()
+		defer s.Close()
+
+		post := func(path string, size int) (int, int) {
+			resp, err := http.Post(fmt.Sprintf("http://%s%s", s.Address(), path), "text/plain", bytes.NewReader(make([]byte, size)))
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, len(b)
+		}
+
+		code, n := post("/small", 8)
+		So(code, ShouldEqual, http.StatusOK)
+		So(n, ShouldEqual, 8)
+		code, _ = post("/small", 9)
+		So(code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		code, n = post("/upload", 1000)
+		So(code, ShouldEqual, http.StatusOK)
+		So(n, ShouldEqual, 1000)
+		code, _ = post("/upload", 1025)
+		So(code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		code, n = post("/unlimited", 4096)
+		So(code, ShouldEqual, http.StatusOK)
+		So(n, ShouldEqual, 4096)
+	})
+}
+
+func TestDefaultLimits(t *testing.T) {
+	Convey("should not limit the request bodies by default", t, func() {
+		s := server.New(server.Options{})
+		s.AddPost("/upload", func(w http.ResponseWriter, r *http.Request) {
+			n, err := io.Copy(io.Discard, r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			fmt.Fprint(w, n)
+		})
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/upload", bytes.NewReader(make([]byte, 16<<20))))
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, "16777216")
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	Convey("should set the request deadline per route", t, func() {
+		s := server.New(server.Options{WriteTimeout: 20 * time.Millisecond})
+		s.AddGet("/slow", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("slow"))
+		}, server.Timeout(time.Second))
+		result := make(chan error, 1)
+		s.AddGet("/deadline", func(w http.ResponseWriter, r *http.Request) {
+			// The client may retry the request after the connection is closed
+			var err error
+			select {
+			case <-r.Context().Done():
+				err = r.Context().Err()
+			case <-time.After(time.Second):
+			}
+			select {
+			case result <- err:
+			default:
+			}
+		}, server.Timeout(10*time.Millisecond))
+		So(s.Start(), ShouldBeNil)
+		<-s.Ready()
+		defer s.Close()
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/slow", s.Address()))
+		So(err, ShouldBeNil)
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		So(string(b), ShouldEqual, "slow")
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/deadline", s.Address()))
+		if err == nil {
+			resp.Body.Close()
+		}
+		So(<-result, ShouldNotBeNil)
+	})
+}
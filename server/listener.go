@@ -0,0 +1,297 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/devfacet/goweb/log"
+)
+
+const (
+	// listenFdsStart is the first inherited file descriptor (systemd socket activation)
+	listenFdsStart = 3
+	// upgradeEnv is the environment variable which marks the listeners passed by Upgrade
+	// The process id of a new process isn't known before it starts so LISTEN_PID can't be set.
+	upgradeEnv = "GOWEB_UPGRADE"
+)
+
+var (
+	inheritedOnce  sync.Once
+	inheritedMu    sync.Mutex
+	inheritedErr   error
+	inheritedCount int
+	inheritedFds   = map[int]net.Listener{}
+	inheritedUsed  = map[int]bool{}
+	inheritedFails = map[int]error{}
+	inheritedNames = map[string]int{}
+	inheritedKept  = []*os.File{}
+)
+
+// initInherited initializes the listeners inherited by LISTEN_FDS, LISTEN_PID and LISTEN_FDNAMES
+// The file descriptors are inherited only if LISTEN_PID is the current process or they are
+// passed by Upgrade. Otherwise the variables are left for another process.
+func initInherited() {
+	// Init vars
+	fds := os.Getenv("LISTEN_FDS")
+	if fds == "" {
+		return
+	}
+	if pid := os.Getenv("LISTEN_PID"); pid != strconv.Itoa(os.Getpid()) && (pid != "" || os.Getenv(upgradeEnv) != "1") {
+		return // not for this process
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		inheritedErr = fmt.Errorf("invalid LISTEN_FDS value %s", fds)
+		return
+	}
+	inheritedCount = n
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < n && i < len(names); i++ {
+		if names[i] == "" {
+			continue
+		}
+		if name, err := url.QueryUnescape(names[i]); err == nil {
+			inheritedNames[name] = listenFdsStart + i
+		}
+	}
+
+	// Child processes shouldn't inherit them again
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDNAMES")
+	os.Unsetenv(upgradeEnv)
+}
+
+// inheritFd returns the listener of the given inherited file descriptor
+// It should be called while inheritedMu is locked.
+func inheritFd(fd int) (net.Listener, error) {
+	if ln, ok := inheritedFds[fd]; ok {
+		return ln, nil
+	}
+	if err, ok := inheritedFails[fd]; ok {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd:%d", fd))
+	if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeSocket == 0 {
+		// The file isn't a socket so it's kept open for its owner
+		inheritedKept = append(inheritedKept, f)
+		if err == nil {
+			err = errors.New("not a socket")
+		}
+		inheritedFails[fd] = fmt.Errorf("failed to inherit file descriptor %d due to %s", fd, err.Error())
+		return nil, inheritedFails[fd]
+	}
+	ln, err := net.FileListener(f)
+	f.Close() // the listener has its own copy
+	if err != nil {
+		inheritedFails[fd] = fmt.Errorf("failed to inherit file descriptor %d due to %s", fd, err.Error())
+		return nil, inheritedFails[fd]
+	}
+	inheritedFds[fd] = ln
+
+	return ln, nil
+}
+
+// InheritedListeners returns the listeners inherited from the parent process which are not used yet
+// Listeners are passed by LISTEN_FDS (i.e. systemd socket activation or Upgrade).
+func InheritedListeners() ([]net.Listener, error) {
+	inheritedOnce.Do(initInherited)
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+
+	result := []net.Listener{}
+	errs := []error{inheritedErr}
+	for i := listenFdsStart; i < listenFdsStart+inheritedCount; i++ {
+		if inheritedUsed[i] {
+			continue
+		}
+		ln, err := inheritFd(i)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result = append(result, ln)
+	}
+	return result, errors.Join(errs...)
+}
+
+// inheritedListener returns the inherited listener by the given address and marks it as used
+// The address is either the name of the listener or a file descriptor (i.e. "fd:3").
+// It returns nil for the other addresses so they are bound instead.
+func inheritedListener(address string) (net.Listener, error) {
+	inheritedOnce.Do(initInherited)
+
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+
+	fd, ok := inheritedNames[address]
+	if !ok {
+		if !strings.HasPrefix(address, "fd:") {
+			return nil, nil
+		}
+		v, err := strconv.Atoi(strings.TrimPrefix(address, "fd:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor address %s", address)
+		}
+		if inheritedErr != nil {
+			return nil, inheritedErr
+		}
+		fd = v
+	}
+	if fd < listenFdsStart || fd >= listenFdsStart+inheritedCount || inheritedUsed[fd] {
+		return nil, fmt.Errorf("file descriptor %d is not inherited", fd)
+	}
+	ln, err := inheritFd(fd)
+	if err != nil {
+		return nil, err
+	}
+	inheritedUsed[fd] = true
+	delete(inheritedFds, fd)
+	delete(inheritedNames, address)
+
+	return ln, nil
+}
+
+// listen returns a listener by the given address
+// Supported addresses are TCP (i.e. "localhost:3000"), Unix sockets (i.e. "unix:/tmp/web.sock")
+// and inherited file descriptors (i.e. "fd:3").
+func listen(address string) (net.Listener, error) {
+	// If the address is inherited then
+	if ln, err := inheritedListener(address); ln != nil || err != nil {
+		return ln, err
+	}
+
+	// Unix socket
+	if strings.HasPrefix(address, "unix:") {
+		p := strings.TrimPrefix(address, "unix:")
+		// Remove the socket file if it's not in use (i.e. left by a crashed process)
+		if fi, err := os.Stat(p); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if c, err := net.Dial("unix", p); err != nil {
+				os.Remove(p)
+			} else {
+				c.Close()
+			}
+		}
+		return net.Listen("unix", p)
+	}
+
+	return net.Listen("tcp", address)
+}
+
+// Upgrade starts a new process of the current executable by passing the listeners of the given servers
+// The new process inherits the listeners by the server addresses so the servers with the same
+// addresses serve them without dropping connections. The servers should be shut down after.
+func Upgrade(s ...*Server) (*os.Process, error) {
+	// Init vars
+	files := []*os.File{}
+	names := []string{}
+	defer func() {
+		for _, v := range files {
+			v.Close()
+		}
+	}()
+
+	// Iterate over the servers and collect the listener files
+	for _, v := range s {
+		v.mu.Lock()
+		ln := v.listener
+		v.mu.Unlock()
+		if ln == nil {
+			return nil, fmt.Errorf("server %s is not listening", v.id)
+		}
+		fl, ok := ln.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			return nil, fmt.Errorf("server %s listener doesn't support file descriptors", v.id)
+		}
+		// The socket file of the Unix listeners should be kept for the new process
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get listener file due to %s", err.Error())
+		}
+		files = append(files, f)
+		names = append(names, url.QueryEscape(v.address))
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no listener to pass")
+	}
+
+	// Start the new process
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade due to %s", err.Error())
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "LISTEN_FDS=") && !strings.HasPrefix(v, "LISTEN_PID=") && !strings.HasPrefix(v, "LISTEN_FDNAMES=") && !strings.HasPrefix(v, upgradeEnv+"=") {
+			cmd.Env = append(cmd.Env, v)
+		}
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("LISTEN_FDS=%d", len(files)), fmt.Sprintf("LISTEN_FDNAMES=%s", strings.Join(names, ":")), upgradeEnv+"=1")
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to upgrade due to %s", err.Error())
+	}
+
+	return cmd.Process, nil
+}
+
+// UpgradeOnSignal upgrades the given servers when the given signal (i.e. syscall.SIGUSR2) is received
+// After the new process is started the servers are shut down gracefully.
+// It returns a function that stops listening for the signal.
+func UpgradeOnSignal(sig os.Signal, s ...*Server) func() {
+	// Init vars
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sig)
+
+	go func() {
+		for {
+			select {
+			case <-c:
+				p, err := Upgrade(s...)
+				if err != nil {
+					log.Logger.Error("failed to upgrade", "error", err)
+					continue
+				}
+				log.Logger.Info("upgraded", "pid", p.Pid)
+				for _, v := range s {
+					v.Shutdown()
+				}
+				signal.Stop(c)
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
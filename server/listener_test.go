@@ -0,0 +1,170 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestServe(t *testing.T) {
+	Convey("should serve the given listener", t, func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+
+		s := server.New(server.Options{})
+		s.AddGet("/test", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("test")) })
+		go s.Serve(ln)
+		<-s.Ready()
+		So(s.Address(), ShouldEqual, ln.Addr().String())
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/test", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "test")
+
+		s.Close()
+	})
+}
+
+func TestListenUnix(t *testing.T) {
+	Convey("should listen on a Unix socket", t, func() {
+		dir, err := ioutil.TempDir("", "goweb")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		p := filepath.Join(dir, "web.sock")
+
+		s := server.New(server.Options{Address: "unix:" + p})
+		s.AddGet("/test", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("test")) })
+		So(s.Start(), ShouldBeNil)
+		So(s.Address(), ShouldEqual, "unix:"+p)
+
+		client := http.Client{Transport: &http.Transport{Dial: func(string, string) (net.Conn, error) {
+			return net.Dial("unix", p)
+		}}}
+		resp, err := client.Get("http://unix/test")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "test")
+
+		s.Close()
+	})
+}
+
+func TestInheritedListeners(t *testing.T) {
+	Convey("should return no inherited listeners", t, func() {
+		ll, err := server.InheritedListeners()
+		So(err, ShouldBeNil)
+		So(ll, ShouldBeEmpty)
+	})
+
+	Convey("should fail to listen due to not inherited file descriptor", t, func() {
+		s := server.New(server.Options{Address: "fd:3"})
+		So(s.Start(), ShouldBeError, "file descriptor 3 is not inherited")
+	})
+
+	// The inherited listeners are initialized once per process so every case runs in a new one
+	run := func(name string, env []string, files ...*os.File) (string, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestInheritedListenersProcess$", "-test.v")
+		cmd.Env = append(os.Environ(), append(env, "GOWEB_TEST_INHERIT="+name)...)
+		cmd.ExtraFiles = files
+		b, err := cmd.CombinedOutput()
+		return string(b), err
+	}
+
+	Convey("should ignore LISTEN_FDS without LISTEN_PID", t, func() {
+		out, err := run("stray", []string{"LISTEN_FDS=1"})
+		So(err, ShouldBeNil)
+		So(out, ShouldContainSubstring, "--- PASS: TestInheritedListenersProcess")
+	})
+
+	Convey("should not close the inherited file descriptors which are not sockets", t, func() {
+		f, err := ioutil.TempFile("", "goweb")
+		So(err, ShouldBeNil)
+		defer os.Remove(f.Name())
+		defer f.Close()
+
+		out, err := run("file", []string{"LISTEN_FDS=1", "GOWEB_UPGRADE=1"}, f)
+		So(err, ShouldBeNil)
+		So(out, ShouldContainSubstring, "--- PASS: TestInheritedListenersProcess")
+	})
+
+	Convey("should serve the named inherited listener", t, func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer ln.Close()
+		f, err := ln.(*net.TCPListener).File()
+		So(err, ShouldBeNil)
+		defer f.Close()
+
+		out, err := run("socket", []string{"LISTEN_FDS=1", "LISTEN_FDNAMES=web", "GOWEB_UPGRADE=1", "GOWEB_TEST_ADDRESS=" + ln.Addr().String()}, f)
+		So(err, ShouldBeNil)
+		So(out, ShouldContainSubstring, "--- PASS: TestInheritedListenersProcess")
+	})
+}
+
+func TestInheritedListenersProcess(t *testing.T) {
+	name := os.Getenv("GOWEB_TEST_INHERIT")
+	if name == "" {
+		t.Skip("runs by TestInheritedListeners")
+	}
+
+	Convey("should inherit the listeners of the "+name+" case", t, func() {
+		switch name {
+		case "stray":
+			ll, err := server.InheritedListeners()
+			So(err, ShouldBeNil)
+			So(ll, ShouldBeEmpty)
+
+			s := server.New(server.Options{Address: "localhost:0"})
+			So(s.Start(), ShouldBeNil)
+			s.Close()
+		case "file":
+			s := server.New(server.Options{Address: "localhost:0"})
+			So(s.Start(), ShouldBeNil)
+			s.Close()
+
+			s = server.New(server.Options{Address: "fd:3"})
+			So(s.Start(), ShouldBeError, "failed to inherit file descriptor 3 due to not a socket")
+			_, err := os.NewFile(3, "fd:3").Stat()
+			So(err, ShouldBeNil)
+		case "socket":
+			s := server.New(server.Options{Address: "web"})
+			s.AddGet("/test", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("test")) })
+			So(s.Start(), ShouldBeNil)
+			So(s.Address(), ShouldEqual, os.Getenv("GOWEB_TEST_ADDRESS"))
+
+			resp, err := http.Get(fmt.Sprintf("http://%s/test", s.Address()))
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			So(string(b), ShouldEqual, "test")
+			s.Close()
+		}
+	})
+}
+
+func TestUpgrade(t *testing.T) {
+	Convey("should fail to upgrade due to not listening server", t, func() {
+		s := server.New(server.Options{ID: "test"})
+		_, err := server.Upgrade(s)
+		So(err, ShouldBeError, "server test is not listening")
+
+		_, err = server.Upgrade()
+		So(err, ShouldBeError, "no listener to pass")
+	})
+}
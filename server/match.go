@@ -0,0 +1,124 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/devfacet/goweb/route"
+)
+
+// Match represents the route a request resolves to
+type Match struct {
+	// StatusCode holds the status code of the resolution
+	// i.e. 200 for a route, 301 for a redirect, 404 for no route and 405 for a disallowed method.
+	StatusCode int
+	// Route holds the matched route (nil if there isn't any)
+	// Route.Middleware returns the middleware chain of the route including the global middleware.
+	Route *route.Route
+	// Params holds the path (and the host) parameters
+	Params map[string]string
+	// Redirect holds the location of the redirect
+	Redirect string
+	// Allow holds the allowed methods for the path when the method is not allowed
+	Allow []string
+}
+
+// Match returns the route that the given method and URL resolve to
+// The URL can be a path (i.e. "/users/1") or an absolute URL for the host based routes.
+// It's useful for debugging the routes that overlap each other (see route.Overlaps).
+func (server *Server) Match(method, rawurl string) (*Match, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url due to %s", err.Error())
+	}
+	method = strings.ToUpper(method)
+
+	return server.match(method, u.Host, u.Path), nil
+}
+
+// match returns the route of the given method, host and path
+func (server *Server) match(method, h, p string) *Match {
+	server.routesMu.RLock()
+	defer server.routesMu.RUnlock()
+
+	// Init vars
+	result := Match{StatusCode: http.StatusNotFound}
+	m := server.mux
+	hp := ""
+	params := map[string]string{}
+
+	// Host
+	if h != "" {
+		if v, pm := server.matchHostPath(h, p); v != nil {
+			m, hp = v.mux, v.pattern
+			for k, v := range pm {
+				params[k] = v
+			}
+		}
+	}
+
+	// Path
+	if cp := cleanPath(p); cp != p {
+		result.StatusCode, result.Redirect = http.StatusMovedPermanently, cp
+		return &result
+	}
+	e, pm, redirect := m.match(p)
+	if redirect != "" {
+		result.StatusCode, result.Redirect = http.StatusMovedPermanently, redirect
+		return &result
+	} else if e == nil {
+		return &result
+	}
+	for k, v := range pm {
+		params[k] = v
+	}
+	if len(params) > 0 {
+		result.Params = params
+	}
+
+	// Mounted servers
+	for _, v := range server.mounts {
+		if v.host == hp && v.prefix+"/" == e.pattern {
+			mr := v.server.match(method, "", strings.TrimPrefix(p, v.prefix))
+			if mr.Redirect != "" {
+				mr.Redirect = v.prefix + mr.Redirect
+			}
+			if mr.Route != nil {
+				o := v.route(*mr.Route)
+				o.Middleware = append(middlewareNames(server.middleware), o.Middleware...)
+				mr.Route = route.New(o)
+			}
+			return mr
+		}
+	}
+
+	// Method
+	key := method
+	if _, ok := e.handlers[method]; !ok {
+		switch {
+		case method == http.MethodHead && e.handlers[http.MethodGet] != nil:
+			key = http.MethodGet
+		case e.any != nil:
+			key = ""
+		case method == http.MethodOptions:
+			result.StatusCode, result.Allow = http.StatusNoContent, e.allow()
+			return &result
+		default:
+			result.StatusCode, result.Allow = http.StatusMethodNotAllowed, e.allow()
+			return &result
+		}
+	}
+	if o, ok := server.routes[strings.TrimSpace(key+" "+hp+e.pattern)]; ok {
+		o.Middleware = append(middlewareNames(server.middleware), o.Middleware...)
+		result.StatusCode, result.Route = http.StatusOK, route.New(o)
+	}
+
+	return &result
+}
@@ -0,0 +1,80 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/devfacet/goweb/page"
+	"github.com/devfacet/goweb/route"
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatch(t *testing.T) {
+	Convey("should return the route that the request resolves to", t, func() {
+		s := server.New(server.Options{})
+		s.Use(testMiddleware("global"))
+		p, err := page.New(page.Options{URLPath: "bar/", MatchAll: true, Content: "bar"})
+		So(err, ShouldBeNil)
+		So(s.AddPage(p), ShouldBeNil)
+		s.AddGet("/bar/baz", func(w http.ResponseWriter, r *http.Request) {}, testMiddleware("route"))
+		s.AddPost("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		s.Host("{tenant}.example.local").AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		blog := server.New(server.Options{})
+		blog.AddGet("/posts/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		s.Mount("/blog", blog)
+
+		m, err := s.Match("GET", "/bar/baz")
+		So(err, ShouldBeNil)
+		So(m.StatusCode, ShouldEqual, http.StatusOK)
+		So(m.Route.Path(), ShouldEqual, "/bar/baz")
+		So(len(m.Route.Middleware()), ShouldEqual, 2)
+
+		m, err = s.Match("head", "/bar/qux")
+		So(err, ShouldBeNil)
+		So(m.StatusCode, ShouldEqual, http.StatusOK)
+		So(m.Route.Path(), ShouldEqual, "/bar/")
+		So(m.Route.Kind(), ShouldEqual, route.KindPage)
+
+		m, err = s.Match("GET", "/bar")
+		So(err, ShouldBeNil)
+		So(m.StatusCode, ShouldEqual, http.StatusMovedPermanently)
+		So(m.Redirect, ShouldEqual, "/bar/")
+		So(m.Route, ShouldBeNil)
+
+		m, err = s.Match("GET", "/users/1")
+		So(err, ShouldBeNil)
+		So(m.StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+		So(m.Allow, ShouldResemble, []string{"OPTIONS", "POST"})
+
+		m, err = s.Match("POST", "/users/1")
+		So(err, ShouldBeNil)
+		So(m.StatusCode, ShouldEqual, http.StatusOK)
+		So(m.Params, ShouldResemble, map[string]string{"id": "1"})
+
+		m, err = s.Match("GET", "http://acme.example.local:8080/users/1")
+		So(err, ShouldBeNil)
+		So(m.StatusCode, ShouldEqual, http.StatusOK)
+		So(m.Route.Host(), ShouldEqual, "{tenant}.example.local")
+		So(m.Params, ShouldResemble, map[string]string{"id": "1", "tenant": "acme"})
+
+		m, err = s.Match("GET", "/blog/posts/1")
+		So(err, ShouldBeNil)
+		So(m.StatusCode, ShouldEqual, http.StatusOK)
+		So(m.Route.Path(), ShouldEqual, "/blog/posts/{id}")
+		So(m.Params, ShouldResemble, map[string]string{"id": "1"})
+		So(len(m.Route.Middleware()), ShouldEqual, 1)
+
+		m, err = s.Match("GET", "/missing")
+		So(err, ShouldBeNil)
+		So(m.StatusCode, ShouldEqual, http.StatusNotFound)
+
+		_, err = s.Match("GET", "%zz")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"runtime"
+)
+
+var (
+	// closureSuffix matches the suffix of the anonymous function names (i.e. ".func1" or ".func1.2")
+	closureSuffix = regexp.MustCompile(`(\.func\d+|\.\d+)+$`)
+	// namedPC holds the function of the middleware that are returned by Named
+	namedPC = reflect.ValueOf(Named("", nil)).Pointer()
+)
+
+// Middleware represents an HTTP middleware
+type Middleware func(http.Handler) http.Handler
+
+// chain wraps the given handler by the given middleware
+// The first middleware is the outermost one so it's invoked first.
+func chain(mw []Middleware, handler http.Handler) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
+
+// middlewareName represents the handler that a named middleware reports its name to (see middlewareNames)
+type middlewareName struct {
+	http.Handler
+	name string
+}
+
+// Named returns the given middleware with the given name
+// The name is reported by the routes (see route.Route.Middleware) instead of the function name of the
+// middleware which is the same for the middleware returned by a factory function (i.e. "pkg.Auth").
+// It isn't inlined so the named middleware share the function that middlewareNames looks for.
+//
+//go:noinline
+func Named(name string, mw Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		if p, ok := next.(*middlewareName); ok {
+			p.name = name
+			return p
+		}
+		return mw(next)
+	}
+}
+
+// middlewareNames returns the names of the given middleware
+// Unnamed middleware are reported by their function names without the closure suffixes
+// (i.e. "pkg.Auth" instead of "pkg.Auth.func1"), so use Named for telling them apart.
+func middlewareNames(mw []Middleware) []string {
+	result := make([]string, 0, len(mw))
+	for _, v := range mw {
+		pc := reflect.ValueOf(v).Pointer()
+		if pc == namedPC {
+			p := &middlewareName{}
+			v(p)
+			result = append(result, p.name)
+			continue
+		}
+		name := "unknown"
+		if f := runtime.FuncForPC(pc); f != nil {
+			name = closureSuffix.ReplaceAllString(path.Base(f.Name()), "")
+		}
+		result = append(result, name)
+	}
+	return result
+}
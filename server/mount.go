@@ -0,0 +1,103 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/devfacet/goweb/route"
+)
+
+type contextKey string
+
+const (
+	// mountPrefixKey is the context key of the mount point prefix
+	mountPrefixKey contextKey = "mountPrefix"
+	// forwardedPrefixKey is the context key of the trusted reverse proxy prefix (see Options.TrustForwardedPrefix)
+	forwardedPrefixKey contextKey = "forwardedPrefix"
+)
+
+// mount represents a server mounted under a path prefix
+type mount struct {
+	host       string
+	prefix     string
+	server     *Server
+	middleware []Middleware
+}
+
+// Mount mounts the given server under the given path prefix
+// Requests under the prefix are served by the other server (including its pages, middleware and
+// error responses) with the prefix stripped from the path. The prefix (followed by the path prefix of the
+// other server) is set into the request context by request.ContextKeys.PathPrefix so the handlers and
+// templates can build links.
+// The given middleware wraps the mounted server only.
+func (router *Router) Mount(prefix string, other *Server, mw ...Middleware) {
+	if router.err != nil {
+		return
+	}
+
+	// Init vars
+	pattern := router.pattern(strings.Trim(prefix, "/") + "/")
+	prefix = strings.TrimSuffix(pattern, "/")
+	mw = append(append([]Middleware{}, router.middleware...), mw...)
+
+	handler := http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Nested mounts append their prefixes
+		mp, _ := r.Context().Value(mountPrefixKey).(string)
+		r = r.WithContext(context.WithValue(r.Context(), mountPrefixKey, mp+prefix))
+		other.ServeHTTP(w, r)
+	}))
+	reg := router.server.handle("", router.host, pattern, router.prefix, handler, route.KindHandler, mw)
+	if reg.err != nil {
+		return
+	}
+
+	router.server.routesMu.Lock()
+	defer router.server.routesMu.Unlock()
+
+	// The routes of the mounted server replace the route of the prefix
+	delete(router.server.routes, reg.key)
+	router.server.mounts = append(router.server.mounts, mount{host: router.host, prefix: prefix, server: other, middleware: mw})
+}
+
+// routes returns the route definitions of the mounted server with the full paths
+func (m mount) routes() []route.Options {
+	// Init vars
+	result := []route.Options{}
+
+	for _, v := range m.server.Routes() {
+		// The root redirect of the mounted server is replaced by the one of the prefix
+		if v.Path() == "" {
+			continue
+		}
+		result = append(result, m.route(v))
+	}
+
+	return result
+}
+
+// route returns the route definition of the given route of the mounted server with the full path
+func (m mount) route(r route.Route) route.Options {
+	// The routes of the default host are served for the host of the mount point
+	h := r.Host()
+	if h == "" {
+		h = m.host
+	}
+
+	return route.Options{
+		Name:       r.Name(),
+		Path:       m.prefix + r.Path(),
+		Host:       h,
+		Pattern:    m.prefix + r.Pattern(),
+		Methods:    r.Methods(),
+		Kind:       r.Kind(),
+		Prefix:     m.prefix + r.Prefix(),
+		Implicit:   !r.Explicit(),
+		Middleware: append(middlewareNames(m.middleware), r.Middleware()...),
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devfacet/goweb/page"
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/route"
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMount(t *testing.T) {
+	Convey("should mount a server under a path prefix", t, func() {
+		blog := server.New(server.Options{ID: "blog"})
+		blog.Use(testMiddleware("blog"))
+		blog.AddGet("/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+			pp, _ := r.Context().Value(request.ContextKeys.PathPrefix).(string)
+			w.Write([]byte(pp + " " + r.URL.Path + " " + request.New(request.Options{Request: r}).Param("id")))
+		})
+		p, err := page.New(page.Options{URLPath: "/", Content: "blog home"})
+		So(err, ShouldBeNil)
+		So(blog.AddPage(p), ShouldBeNil)
+
+		s := server.New(server.Options{ID: "main"})
+		s.Use(testMiddleware("main"))
+		s.AddGet("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("main")) })
+		s.Group("/apps").Mount("/blog", blog, testMiddleware("mount"))
+
+		rl := s.Routes()
+		So(len(rl), ShouldEqual, 5)
+		So(rl[1].Path(), ShouldEqual, "/")
+		So(rl[2].Path(), ShouldEqual, "/apps/blog")
+		So(rl[2].Redirect(), ShouldBeTrue)
+		So(rl[3].Path(), ShouldEqual, "/apps/blog/")
+		So(rl[3].Kind(), ShouldEqual, route.KindPage)
+		So(rl[4].Path(), ShouldEqual, "/apps/blog/posts/{id}")
+		So(rl[4].Prefix(), ShouldEqual, "/apps/blog")
+		So(rl[4].Methods(), ShouldResemble, []string{"GET"})
+		So(rl[4].Middleware(), ShouldResemble, []string{"main", "mount", "blog"})
+
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/apps/blog/posts/1")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "/apps/blog /posts/1 1")
+		So(strings.Join(resp.Header["X-Middleware"], ","), ShouldEqual, "main,mount,blog")
+
+		resp, err = http.Get(ts.URL + "/apps/blog")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "blog home")
+
+		resp, err = http.Get(ts.URL + "/apps/blog/missing")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+
+		resp, err = http.Get(ts.URL + "/")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "main")
+	})
+	Convey("should redirect to the subtrees of a mounted server with the prefix", t, func() {
+		docs := server.New(server.Options{ID: "docs"})
+		docs.AddGet("/docs/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.URL.Path)) })
+
+		s := server.New(server.Options{ID: "main"})
+		s.Mount("/app", docs)
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/app/docs?v=1", nil))
+		So(w.Code, ShouldEqual, http.StatusMovedPermanently)
+		So(w.Header().Get("Location"), ShouldEqual, "/app/docs/?v=1")
+		m, err := s.Match("GET", "/app/docs")
+		So(err, ShouldBeNil)
+		So(m.Redirect, ShouldEqual, "/app/docs/")
+
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/app/docs/intro", nil))
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, "/docs/intro")
+	})
+}
@@ -0,0 +1,213 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/route"
+)
+
+// patternKey returns a key that is identical for the patterns matching the same paths
+func patternKey(segs []route.Segment) string {
+	var b strings.Builder
+	for _, v := range segs {
+		b.WriteByte('/')
+		switch v.Kind {
+		case route.SegmentStatic:
+			b.WriteString(v.Value)
+		case route.SegmentParam:
+			b.WriteString("{}")
+		case route.SegmentWildcard:
+			// A subtree (trailing slash) matches the same paths as a named wildcard
+			b.WriteString("{...}")
+		}
+	}
+	return b.String()
+}
+
+// matchSegments matches the given path parts against the given segments
+func matchSegments(segs []route.Segment, parts []string) (map[string]string, bool) {
+	var params map[string]string
+	for i, v := range segs {
+		if v.Kind == route.SegmentWildcard {
+			// A wildcard needs at least one more part (can be empty, i.e. trailing slash)
+			if i >= len(parts) {
+				return nil, false
+			}
+			if v.Value != "" {
+				if params == nil {
+					params = map[string]string{}
+				}
+				params[v.Value] = strings.Join(parts[i:], "/")
+			}
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		switch v.Kind {
+		case route.SegmentStatic:
+			if parts[i] != v.Value {
+				return nil, false
+			}
+		case route.SegmentParam:
+			if parts[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = map[string]string{}
+			}
+			params[v.Value] = parts[i]
+		}
+	}
+
+	return params, len(parts) == len(segs)
+}
+
+// splitPath splits the given path into parts
+func splitPath(p string) []string {
+	return strings.Split(strings.TrimPrefix(p, "/"), "/")
+}
+
+// cleanPath returns the canonical path of the given path (same as http.ServeMux)
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		// Avoid allocating a new string if the path is already clean
+		if len(p) == len(np)+1 && strings.HasPrefix(p, np) {
+			return p
+		}
+		np += "/"
+	}
+	return np
+}
+
+// mux represents an HTTP request multiplexer that supports path parameters
+type mux struct {
+	matcher  matcher
+	patterns map[string]*endpoint
+	keys     map[string]*endpoint
+}
+
+// newMux returns a new mux by the given matcher kind
+func newMux(kind Matcher) *mux {
+	return &mux{
+		matcher:  newMatcher(kind),
+		patterns: map[string]*endpoint{},
+		keys:     map[string]*endpoint{},
+	}
+}
+
+// endpoint returns the endpoint of the given pattern by creating it if it doesn't exist
+func (m *mux) endpoint(pattern string) (*endpoint, error) {
+	if e, ok := m.patterns[pattern]; ok {
+		return e, nil
+	}
+
+	segs, err := route.ParsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	key := patternKey(segs)
+	if e, ok := m.keys[key]; ok {
+		return nil, fmt.Errorf("pattern %s conflicts with %s", pattern, e.pattern)
+	}
+
+	e := newEndpoint(pattern)
+	e.segments = segs
+	m.matcher.add(e)
+	m.patterns[pattern] = e
+	m.keys[key] = e
+
+	return e, nil
+}
+
+// match returns the most specific endpoint and its parameters by the given path
+// If the path should be redirected to its subtree then the redirect path is returned instead.
+func (m *mux) match(p string) (*endpoint, map[string]string, string) {
+	e, params := m.matcher.match(p)
+
+	// If there is a more specific subtree for the path with a trailing slash then redirect (same as http.ServeMux)
+	// Exact matches are never redirected.
+	if !strings.HasSuffix(p, "/") && (e == nil || e.segments[len(e.segments)-1].Kind == route.SegmentWildcard) {
+		if se, _ := m.matcher.match(p + "/"); se != nil {
+			l := len(se.segments)
+			if l == strings.Count(p, "/")+1 && se.segments[l-1].Kind == route.SegmentWildcard && se.segments[l-1].Value == "" {
+				if e == nil || route.CompareSegments(se.segments, e.segments) > 0 {
+					return nil, nil, p + "/"
+				}
+			}
+		}
+	}
+
+	return e, params, ""
+}
+
+// handler returns the handler of the matching endpoint and the request with the path parameters
+// The mux is only read so the caller should hold the read lock of the routes, and serve the
+// handler after releasing it (see Server.routeHost).
+func (m *mux) handler(r *http.Request) (http.Handler, *http.Request) {
+	// Redirect to the canonical path
+	if r.Method != http.MethodConnect {
+		if p := cleanPath(r.URL.Path); p != r.URL.Path {
+			return redirectHandler(p), r
+		}
+	}
+
+	e, params, redirect := m.match(r.URL.Path)
+	if redirect != "" {
+		return redirectHandler(redirect), r
+	}
+	if e == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			replyError(w, http.StatusNotFound, "")
+		}), r
+	}
+
+	// Set the path parameters (after the host parameters if there is any)
+	if len(params) > 0 {
+		if hp, ok := r.Context().Value(request.ContextKeys.Params).(map[string]string); ok {
+			for k, v := range hp {
+				if _, ok := params[k]; !ok {
+					params[k] = v
+				}
+			}
+		}
+		r = r.WithContext(context.WithValue(r.Context(), request.ContextKeys.Params, params))
+	}
+	return e.handler(r.Method), r
+}
+
+// redirectHandler returns a handler that redirects the requests to the given path (see redirectPath)
+func redirectHandler(p string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirectPath(w, r, p)
+	})
+}
+
+// redirectPath redirects the request to the given path permanently
+// The path is prefixed by the trusted prefix of a reverse proxy (see Options.TrustForwardedPrefix)
+// and the mount point prefix if the server is mounted (see Mount).
+func redirectPath(w http.ResponseWriter, r *http.Request, p string) {
+	fp, _ := r.Context().Value(forwardedPrefixKey).(string)
+	mp, _ := r.Context().Value(mountPrefixKey).(string)
+	u := *r.URL
+	u.Path = fp + mp + p
+	u.RawPath = ""
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
@@ -0,0 +1,198 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/devfacet/goweb/page"
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/route"
+)
+
+// Router represents a set of routes sharing a path prefix and middleware
+type Router struct {
+	server     *Server
+	host       string
+	prefix     string
+	middleware []Middleware
+	err        error
+}
+
+// Host returns the host pattern of the router (empty for the default host)
+func (router *Router) Host() string {
+	return router.host
+}
+
+// Prefix returns the path prefix of the router
+func (router *Router) Prefix() string {
+	return router.prefix
+}
+
+// Group returns a new router by the given path prefix and middleware
+// The prefix and the middleware are appended to the ones of the router.
+func (router *Router) Group(prefix string, mw ...Middleware) *Router {
+	result := Router{
+		server:     router.server,
+		host:       router.host,
+		prefix:     router.prefix,
+		middleware: append(append([]Middleware{}, router.middleware...), mw...),
+		err:        router.err,
+	}
+	if p := strings.Trim(prefix, "/"); p != "" {
+		result.prefix = fmt.Sprintf("%s/", router.pattern(p))
+	}
+
+	return &result
+}
+
+// Use adds the given middleware to the router
+// It wraps the handlers and pages registered after it's added.
+func (router *Router) Use(mw ...Middleware) {
+	router.middleware = append(router.middleware, mw...)
+}
+
+// pattern returns the mux pattern by the given pattern
+func (router *Router) pattern(pattern string) string {
+	if router.prefix != "" {
+		return fmt.Sprintf("%s%s", router.prefix, strings.TrimLeft(pattern, "/"))
+	}
+	return fmt.Sprintf("/%s", strings.TrimLeft(pattern, "/"))
+}
+
+// handle registers the given handler by the router
+func (router *Router) handle(method, pattern string, handler http.Handler, kind route.Kind, mw []Middleware) *Registration {
+	if router.err != nil {
+		return &Registration{server: router.server, err: router.err}
+	}
+	mw = append(append([]Middleware{}, router.middleware...), mw...)
+	return router.server.handle(method, router.host, pattern, router.prefix, handler, kind, mw)
+}
+
+// stripPrefix returns the static part of the given pattern (up to the first path parameter)
+func stripPrefix(pattern string) string {
+	if i := strings.Index(pattern, "{"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// AddHandler adds a handler
+// The pattern can be qualified by an HTTP method (i.e. "POST /foo").
+// The given middleware wraps the handler only. The returned registration names the route (see URLFor).
+func (router *Router) AddHandler(pattern string, handler http.Handler, mw ...Middleware) *Registration {
+	method, pattern := splitPattern(pattern)
+	pattern = router.pattern(pattern)
+	return router.handle(method, pattern, http.StripPrefix(stripPrefix(pattern), handler), route.KindHandler, mw)
+}
+
+// AddHandlerFunc adds a handler function
+// The pattern can be qualified by an HTTP method (i.e. "POST /foo").
+// The given middleware wraps the handler only.
+func (router *Router) AddHandlerFunc(pattern string, handler func(http.ResponseWriter, *http.Request), mw ...Middleware) *Registration {
+	method, pattern := splitPattern(pattern)
+	return router.handle(method, router.pattern(pattern), http.HandlerFunc(handler), route.KindHandler, mw)
+}
+
+// AddMethodHandlerFunc adds a handler function for the given HTTP method
+func (router *Router) AddMethodHandlerFunc(method, pattern string, handler func(http.ResponseWriter, *http.Request), mw ...Middleware) *Registration {
+	return router.handle(strings.ToUpper(method), router.pattern(pattern), http.HandlerFunc(handler), route.KindHandler, mw)
+}
+
+// AddGet adds a handler function for GET requests
+func (router *Router) AddGet(pattern string, handler func(http.ResponseWriter, *http.Request), mw ...Middleware) *Registration {
+	return router.AddMethodHandlerFunc(http.MethodGet, pattern, handler, mw...)
+}
+
+// AddPost adds a handler function for POST requests
+func (router *Router) AddPost(pattern string, handler func(http.ResponseWriter, *http.Request), mw ...Middleware) *Registration {
+	return router.AddMethodHandlerFunc(http.MethodPost, pattern, handler, mw...)
+}
+
+// AddPut adds a handler function for PUT requests
+func (router *Router) AddPut(pattern string, handler func(http.ResponseWriter, *http.Request), mw ...Middleware) *Registration {
+	return router.AddMethodHandlerFunc(http.MethodPut, pattern, handler, mw...)
+}
+
+// AddPatch adds a handler function for PATCH requests
+func (router *Router) AddPatch(pattern string, handler func(http.ResponseWriter, *http.Request), mw ...Middleware) *Registration {
+	return router.AddMethodHandlerFunc(http.MethodPatch, pattern, handler, mw...)
+}
+
+// AddDelete adds a handler function for DELETE requests
+func (router *Router) AddDelete(pattern string, handler func(http.ResponseWriter, *http.Request), mw ...Middleware) *Registration {
+	return router.AddMethodHandlerFunc(http.MethodDelete, pattern, handler, mw...)
+}
+
+// AddPage adds a page
+// The page url path can contain path parameters (i.e. "/docs/{slug}") which are
+// available to the page template by the param function (i.e. {{param "slug"}}).
+// The given middleware wraps the page only.
+func (router *Router) AddPage(p *page.Page, mw ...Middleware) error {
+	// Init vars
+	puf := p.URLPath()
+
+	if puf == "" {
+		return errors.New("invalid page url")
+	}
+
+	puf = router.pattern(puf)
+	if _, err := route.ParsePattern(puf); err != nil {
+		return err
+	}
+	ts := strings.HasSuffix(puf, "/")
+	pc := strings.Count(puf, "/")
+
+	// Define handler function
+	h := func(w http.ResponseWriter, r *http.Request) {
+		// If match all is false, page url has trailing slash and requested url path is not the page url then
+		if !p.MatchAll() && ts && (!strings.HasSuffix(r.URL.Path, "/") || strings.Count(r.URL.Path, "/") != pc) {
+			request.New(request.Options{Request: r, Writer: w}).Reply(request.Error{
+				StatusCode: 404,
+			})
+			return
+		}
+
+		// Execute the template and write into response
+		req := request.New(request.Options{Request: r})
+		var data interface{}
+		if params := req.Params(); len(params) > 0 && p.TemplateData() == nil {
+			data = params // use the parameters as template data if the page doesn't have any
+		}
+		err := p.TemplateExecuteFuncs(w, data, page.RequestFuncs(req))
+		if err != nil {
+			request.New(request.Options{Request: r, Writer: w}).Reply(request.Error{
+				StatusCode: 500,
+				Internal:   err,
+			})
+			return
+		}
+	}
+	reg := router.handle("", puf, http.HandlerFunc(h), route.KindPage, mw)
+	if reg.err != nil {
+		return reg.err
+	}
+	if p.Name() != "" {
+		return reg.name(p.Name())
+	}
+
+	return nil
+}
+
+// AddPages adds pages
+func (router *Router) AddPages(p ...*page.Page) error {
+	// Iterate over pages
+	for _, v := range p {
+		if err := router.AddPage(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
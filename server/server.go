@@ -9,12 +9,19 @@ package server
 import (
 	"context"
 	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
-	"math/rand"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/devfacet/goweb/log"
@@ -23,96 +30,188 @@ import (
 	"github.com/devfacet/goweb/route"
 )
 
+const (
+	defaultShutdownTimeout = 10 * time.Second
+)
+
 // Options represents the options than can be set when creating a new server
 type Options struct {
 	// ID of the server
 	ID string
 	// Address of the server
+	// Supported addresses are TCP (i.e. "localhost:3000"), Unix sockets (i.e. "unix:/tmp/web.sock")
+	// and inherited file descriptors (i.e. "fd:3"). An inherited listener with the same name is
+	// used instead of binding the address (see InheritedListeners). File descriptors are inherited
+	// only if LISTEN_PID is the current process or they are passed by Upgrade.
 	Address string
 	// PathPrefix holds HTTP path prefix
 	PathPrefix string
+	// TrustForwardedPrefix enables the path prefix sent by a reverse proxy by the X-Forwarded-Prefix header
+	// It should be enabled only if the server is reachable through a proxy that sets or removes the header,
+	// since clients can send any value otherwise.
+	TrustForwardedPrefix bool
+	// RouteWarnings logs the routes that overlap each other when the server starts (see route.Overlaps)
+	RouteWarnings bool
+	// Matcher holds the kind of the route matcher (default MatcherTree)
+	Matcher Matcher
+	// ReadTimeout holds the maximum duration for reading the entire request including the body (zero means no limit)
+	ReadTimeout time.Duration
+	// ReadHeaderTimeout holds the maximum duration for reading the request headers (default 10 seconds)
+	// Zero means the default value and a negative value disables the timeout. The same applies to
+	// IdleTimeout and MaxHeaderBytes.
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout holds the maximum duration before timing out writes of the response (zero means no limit)
+	// It can be overridden per route by the Timeout middleware.
+	WriteTimeout time.Duration
+	// IdleTimeout holds the maximum duration for waiting the next request on keep-alive connections (default 120 seconds)
+	IdleTimeout time.Duration
+	// MaxHeaderBytes holds the maximum size of the request headers (default 1 MB)
+	MaxHeaderBytes int
+	// MaxBodyBytes holds the maximum size of the request bodies (zero means no limit)
+	// It can be overridden per route by the MaxBodySize middleware.
+	MaxBodyBytes int64
+	// ShutdownTimeout holds the maximum duration for waiting in-flight requests and shutdown hooks
+	// before closing the remaining connections (default 10 seconds)
+	ShutdownTimeout time.Duration
+	// TLSCertFile holds the TLS certificate file path (HTTPS is served if it's set)
+	TLSCertFile string
+	// TLSKeyFile holds the TLS key file path
+	TLSKeyFile string
+	// TLSCertificates holds additional certificate files which are picked by SNI
+	TLSCertificates []TLSCertificate
+	// TLSConfig holds the TLS config (HTTPS is served if it's set)
+	// If there are certificate files then they are preferred over the certificates of the config,
+	// which are used only for the client hellos that no certificate file supports. The GetCertificate
+	// function of the config precedes both.
+	TLSConfig *tls.Config
+	// TLSReloadInterval holds the minimum interval for checking certificate files for changes
+	// Default is 10 seconds and a negative value disables reloading.
+	TLSReloadInterval time.Duration
+	// TLSClientCAs holds the certificate authorities for verifying client certificates
+	TLSClientCAs *x509.CertPool
+	// TLSClientAuth holds the client certificate verification mode
+	// Default is tls.RequireAndVerifyClientCert if TLSClientCAs is set.
+	// Use tls.VerifyClientCertIfGiven for verifying only the given certificates.
+	TLSClientAuth tls.ClientAuthType
 }
 
 // New returns a new web server by the given options
 func New(o Options) *Server {
 	// Init the server
 	server := Server{
-		isInit:     true,
-		id:         o.ID,
-		address:    o.Address,
-		pathPrefix: o.PathPrefix,
-		mux:        http.NewServeMux(),
-		ctx:        context.Background(),
+		isInit:          true,
+		id:              o.ID,
+		address:         o.Address,
+		pathPrefix:      o.PathPrefix,
+		trustForwarded:  o.TrustForwardedPrefix,
+		matcher:         o.Matcher,
+		routeWarnings:   o.RouteWarnings,
+		mux:             newMux(o.Matcher),
+		hosts:           map[string]*host{},
+		routes:          map[string]route.Options{},
+		names:           map[string]string{},
+		shutdownTimeout: o.ShutdownTimeout,
+
+		readTimeout:       time.Duration(limitValue(int64(o.ReadTimeout), 0)),
+		readHeaderTimeout: time.Duration(limitValue(int64(o.ReadHeaderTimeout), int64(defaultReadHeaderTimeout))),
+		writeTimeout:      time.Duration(limitValue(int64(o.WriteTimeout), 0)),
+		idleTimeout:       time.Duration(limitValue(int64(o.IdleTimeout), int64(defaultIdleTimeout))),
+		maxHeaderBytes:    int(limitValue(int64(o.MaxHeaderBytes), defaultMaxHeaderBytes)),
+		maxBodyBytes:      limitValue(o.MaxBodyBytes, 0),
+		ready:             make(chan struct{}),
+		state:             StateNew,
+
+		tls:               o.TLSConfig,
+		tlsCertFile:       o.TLSCertFile,
+		tlsKeyFile:        o.TLSKeyFile,
+		tlsCertificates:   o.TLSCertificates,
+		tlsReloadInterval: o.TLSReloadInterval,
+		tlsClientCAs:      o.TLSClientCAs,
+		tlsClientAuth:     o.TLSClientAuth,
+	}
+
+	if server.id == "" {
+		// Use md5 checksum for server id
+		if server.address == "" {
+			server.id = fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("localhost:%d", time.Now().UnixNano()))))
+		} else {
+			server.id = fmt.Sprintf("%x", md5.Sum([]byte(server.address)))
+		}
 	}
 
 	if server.address == "" {
-		// Use a random port number
-		rand.Seed(time.Now().UTC().UnixNano())
-		server.address = fmt.Sprintf("localhost:%d", rand.Intn(65535-3000)+3000)
+		// Let the system pick an available port
+		server.address = "localhost:0"
 	}
 
-	if server.id == "" {
-		// Use md5 checksum for server id
-		server.id = fmt.Sprintf("%x", md5.Sum([]byte(server.address)))
+	if server.shutdownTimeout == 0 {
+		server.shutdownTimeout = defaultShutdownTimeout
 	}
 
 	if server.pathPrefix != "" {
 		server.pathRoot = fmt.Sprintf("/%s/", strings.Trim(server.pathPrefix, "/"))
 	}
 
+	// Init the root router
+	server.Router = &Router{server: &server, prefix: server.pathRoot}
+
 	return &server
 }
 
 // ListenAll invokes listen for the given servers
+// It blocks until all the servers are closed or one of them fails (see ServerGroup).
 func ListenAll(s ...*Server) error {
-	// Init vars
-	var result error
-	sl := len(s)
-	wg := sync.WaitGroup{}
-	wg.Add(sl)
-
-	// Iterate over the servers
-	for _, v := range s {
-		go func(v *Server) {
-			// Listen
-			if err := v.Listen(); err != nil && err != http.ErrServerClosed {
-				// If there is no error before then
-				if result == nil {
-					result = err // set error
-					// Cleanup all
-					for i := 0; i < sl; i++ {
-						wg.Done()
-					}
-				}
-			} else {
-				// Otherwise if there is no error then
-				if result == nil {
-					wg.Done() // done with the server
-				}
-			}
-		}(v)
-	}
-	wg.Wait()
-
-	// Shutdown all
-	for i := 0; i < sl; i++ {
-		s[i].Shutdown()
-	}
-
-	return result
+	return NewServerGroup(s...).Listen(context.Background())
 }
 
 // Server represents a web server
+// The handlers and pages are registered by the embedded root router.
 type Server struct {
-	isInit     bool
-	id         string
-	address    string
-	pathPrefix string
-	pathRoot   string
-	pages      []*page.Page
-	http       *http.Server
-	mux        *http.ServeMux
-	ctx        context.Context
+	*Router
+	isInit          bool
+	id              string
+	address         string
+	pathPrefix      string
+	pathRoot        string
+	trustForwarded  bool
+	pages           []*page.Page
+	http            *http.Server
+	matcher         Matcher
+	routeWarnings   bool
+	mux             *mux
+	hosts           map[string]*host
+	hostList        []*host
+	routes          map[string]route.Options
+	routesMu        sync.RWMutex
+	middleware      []Middleware
+	handler         http.Handler
+	mounts          []mount
+	routeErrs       []error
+	names           map[string]string
+	shutdownTimeout time.Duration
+	shutdownHooks   []func(context.Context)
+
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	maxBodyBytes      int64
+	mu                sync.Mutex
+	listener          net.Listener
+	boundAddress      string
+	ready             chan struct{}
+	done              chan struct{}
+	serveErr          error
+	state             State
+
+	tls               *tls.Config
+	tlsCertFile       string
+	tlsKeyFile        string
+	tlsCertificates   []TLSCertificate
+	tlsReloadInterval time.Duration
+	tlsClientCAs      *x509.CertPool
+	tlsClientAuth     tls.ClientAuthType
 }
 
 // ID returns the server id
@@ -121,8 +220,28 @@ func (server *Server) ID() string {
 }
 
 // Address returns the server address
+// If the server is listening then the address has the port that is actually bound.
 func (server *Server) Address() string {
-	return server.address
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.listener == nil {
+		return server.address
+	}
+	return server.boundAddress
+}
+
+// boundAddress returns the address of the given listener by keeping the host of the given address (i.e. localhost)
+func boundAddress(address string, ln net.Listener) string {
+	if ln.Addr().Network() == "unix" {
+		return fmt.Sprintf("unix:%s", ln.Addr().String())
+	}
+	host, _, err := net.SplitHostPort(address)
+	_, port, perr := net.SplitHostPort(ln.Addr().String())
+	if err != nil || perr != nil || host == "" {
+		return ln.Addr().String()
+	}
+	return net.JoinHostPort(host, port)
 }
 
 // PathRoot returns the server path prefix
@@ -130,114 +249,527 @@ func (server *Server) PathRoot() string {
 	return server.pathRoot
 }
 
+// Ready returns a channel that is closed when the server listener is bound
+func (server *Server) Ready() <-chan struct{} {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.ready
+}
+
+// State returns the state of the server
+func (server *Server) State() State {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.state
+}
+
 // Listen initializes the server and listens for requests
+// It blocks until the server is closed.
 func (server *Server) Listen() error {
-	// Route list
-	for _, v := range server.Routes() {
-		log.Logger.Printf("route definition: %s > %s - explicit:%t, redirect:%t", v.Path(), v.Pattern(), v.Explicit(), v.Redirect())
+	if err := server.Start(); err != nil {
+		return err
+	}
+	return server.wait()
+}
+
+// Start initializes the server and listens for requests in the background
+// It returns after the listener is bound.
+func (server *Server) Start() error {
+	if err := server.starting(); err != nil {
+		return err
+	}
+
+	// TLS
+	tc, err := server.tlsConfig()
+	if err != nil {
+		server.setState(StateFailed)
+		return err
 	}
 
 	// Listen
-	server.http = &http.Server{Addr: server.address, Handler: server.mux, ErrorLog: log.Logger}
-	var err error
-	wg := sync.WaitGroup{}
-	wg.Add(1)
+	ln, err := listen(server.address)
+	if err != nil {
+		server.setState(StateFailed)
+		return err
+	}
+
+	server.start(ln, tc, boundAddress(server.address, ln))
+	return nil
+}
+
+// Serve serves the given listener
+// It blocks until the server is closed.
+func (server *Server) Serve(ln net.Listener) error {
+	if err := server.starting(); err != nil {
+		return err
+	}
+
+	// TLS
+	tc, err := server.tlsConfig()
+	if err != nil {
+		server.setState(StateFailed)
+		return err
+	}
+
+	server.start(ln, tc, boundAddress("", ln))
+	return server.wait()
+}
+
+// starting sets the server state as starting unless it's already started
+func (server *Server) starting() error {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.state == StateStarting || server.state == StateServing {
+		return errors.New("server is already started")
+	}
+	if err := server.Err(); err != nil {
+		return err
+	}
+	server.state = StateStarting
+
+	// If the server is restarted then it needs a new ready channel
+	select {
+	case <-server.ready:
+		server.ready = make(chan struct{})
+	default:
+	}
+
+	return nil
+}
+
+// setState sets the server state
+func (server *Server) setState(state State) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.state = state
+}
+
+// start serves the given listener in the background
+func (server *Server) start(ln net.Listener, tc *tls.Config, address string) {
+	server.mu.Lock()
+	server.listener = ln
+	server.boundAddress = address
+	server.http = &http.Server{
+		Handler:           server.Handler(),
+		ErrorLog:          log.StdLogger(log.Logger, log.LevelError),
+		TLSConfig:         tc,
+		ReadTimeout:       server.readTimeout,
+		ReadHeaderTimeout: server.readHeaderTimeout,
+		WriteTimeout:      server.writeTimeout,
+		IdleTimeout:       server.idleTimeout,
+		MaxHeaderBytes:    server.maxHeaderBytes,
+	}
+	server.done = make(chan struct{})
+	server.state = StateServing
+	hs, done, ready := server.http, server.done, server.ready
+	server.mu.Unlock()
+
+	// Route list
+	rl := server.Routes()
+	if log.Logger.Enabled(log.LevelDebug) {
+		for _, v := range rl {
+			log.Logger.Debug("route definition", "server", server.id, "methods", strings.Join(v.Methods(), ","), "host", v.Host(),
+				"path", v.Path(), "pattern", v.Pattern(), "name", v.Name(), "kind", v.Kind(), "middleware", strings.Join(v.Middleware(), ","))
+		}
+	}
+	if server.routeWarnings {
+		for _, v := range route.Overlaps(rl) {
+			log.Logger.Warn("route overlap", "server", server.id, "overlap", v.String())
+		}
+	}
+
+	// Serve
+	if tc != nil {
+		log.Logger.Info("listening", "server", server.id, "address", address, "tls", true)
+	} else {
+		log.Logger.Info("listening", "server", server.id, "address", address)
+	}
+	close(ready)
 	go func() {
-		log.Logger.Printf("%s listening on %s", server.id, server.address)
-		err = server.http.ListenAndServe()
-		wg.Done()
+		var err error
+		if tc != nil {
+			err = hs.ServeTLS(ln, "", "")
+		} else {
+			err = hs.Serve(ln)
+		}
+		server.mu.Lock()
+		server.serveErr = err
+		if err == http.ErrServerClosed {
+			server.state = StateStopped
+		} else {
+			server.state = StateFailed
+		}
+		server.mu.Unlock()
+		close(done)
 	}()
-	wg.Wait()
-	return err
+}
+
+// wait waits until the server stops serving and returns the serve error
+func (server *Server) wait() error {
+	server.mu.Lock()
+	done := server.done
+	server.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	<-done
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.serveErr
 }
 
 // Close closes all active listeners and connections immediately
 func (server *Server) Close() error {
-	if server.http != nil {
-		return server.http.Close()
+	server.mu.Lock()
+	hs := server.http
+	server.mu.Unlock()
+
+	if hs != nil {
+		return hs.Close()
 	}
 	return nil
 }
 
 // Shutdown gracefully shuts down the server
+// It waits for in-flight requests up to the shutdown timeout and then closes the remaining connections.
 func (server *Server) Shutdown() error {
-	if server.http != nil {
-		return server.http.Shutdown(server.ctx)
+	ctx, cancel := context.WithTimeout(context.Background(), server.shutdownTimeout)
+	defer cancel()
+
+	return server.ShutdownContext(ctx)
+}
+
+// ShutdownContext gracefully shuts down the server by the given context
+// It stops accepting new connections, invokes the shutdown hooks and waits for in-flight requests
+// and the hooks until the context is done. Then the remaining connections are closed.
+func (server *Server) ShutdownContext(ctx context.Context) error {
+	server.mu.Lock()
+	hs := server.http
+	hooks := server.shutdownHooks
+	if server.state != StateServing {
+		hooks = nil
 	}
-	return nil
+	server.mu.Unlock()
+
+	if hs == nil {
+		return nil
+	}
+
+	// Invoke the shutdown hooks
+	wg := sync.WaitGroup{}
+	wg.Add(len(hooks))
+	for _, v := range hooks {
+		go func(f func(context.Context)) {
+			defer wg.Done()
+			f(ctx)
+		}(v)
+	}
+	hooksDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(hooksDone)
+	}()
+
+	// Wait for in-flight requests and the hooks
+	err := hs.Shutdown(ctx)
+	select {
+	case <-hooksDone:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+
+	// If the deadline is exceeded then close the remaining connections
+	if err != nil {
+		log.Logger.Error("failed to shut down gracefully", "server", server.id, "error", err)
+		hs.Close()
+	}
+
+	return err
 }
 
-// Routes returns the list of the routes
-func (server *Server) Routes() []route.Route {
-	return route.ListByMux(server.mux)
+// OnShutdown adds a function that is invoked when the server is shut down
+// The function should stop long-lived handlers (i.e. SSE, WebSocket) and background jobs and return
+// once they are drained. The given context is done when the shutdown deadline is exceeded.
+func (server *Server) OnShutdown(f func(ctx context.Context)) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	server.shutdownHooks = append(server.shutdownHooks, f)
 }
 
-// AddHandler adds a handler
-func (server *Server) AddHandler(pattern string, handler http.Handler) {
-	if server.pathRoot != "" {
-		pattern = fmt.Sprintf("%s%s", server.pathRoot, strings.TrimLeft(pattern, "/"))
-	} else {
-		pattern = fmt.Sprintf("/%s", strings.TrimLeft(pattern, "/"))
+// Run starts the server and blocks until the given context is done or an interrupt/terminate signal is received
+// Then it shuts down the server gracefully (see Shutdown).
+func (server *Server) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := server.Start(); err != nil {
+		return err
+	}
+
+	// Wait for the server or the context
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.wait()
+	}()
+	select {
+	case err := <-errc:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		log.Logger.Info("shutting down", "server", server.id)
+		err := server.Shutdown()
+		<-errc
+		return err
 	}
-	server.mux.Handle(pattern, http.StripPrefix(pattern, handler))
 }
 
-// AddHandlerFunc adds a handler function
-func (server *Server) AddHandlerFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
-	if server.pathRoot != "" {
-		pattern = fmt.Sprintf("%s%s", server.pathRoot, strings.TrimLeft(pattern, "/"))
-	} else {
-		pattern = fmt.Sprintf("/%s", strings.TrimLeft(pattern, "/"))
+// Err returns the errors of the route registrations (i.e. conflicting patterns)
+// The server fails to start if there is any.
+func (server *Server) Err() error {
+	server.routesMu.RLock()
+	defer server.routesMu.RUnlock()
+
+	return errors.Join(server.routeErrs...)
+}
+
+// Routes returns the list of the routes
+// The registrations that share the same host, path and kind are reported as a single route with
+// their methods (i.e. GET and POST /users). The route has no methods if any of them serves any method.
+func (server *Server) Routes() []route.Route {
+	server.routesMu.RLock()
+	defer server.routesMu.RUnlock()
+
+	// Sort the keys first so routes on the same path are in a stable order
+	keys := make([]string, 0, len(server.routes))
+	for k := range server.routes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Merge the registrations of the same path
+	type pathKey struct {
+		host, path string
+		kind       route.Kind
 	}
-	server.mux.HandleFunc(pattern, handler)
+	paths := map[pathKey]int{}
+	merged := make([]route.Options, 0, len(keys))
+	for _, k := range keys {
+		o := server.routes[k]
+		pk := pathKey{host: o.Host, path: o.Path, kind: o.Kind}
+		i, ok := paths[pk]
+		if !ok {
+			paths[pk] = len(merged)
+			o.Methods = append([]string{}, o.Methods...)
+			o.Middleware = append([]string{}, o.Middleware...)
+			merged = append(merged, o)
+			continue
+		}
+		m := &merged[i]
+		if len(m.Methods) > 0 && len(o.Methods) > 0 {
+			m.Methods = append(m.Methods, o.Methods...)
+			sort.Strings(m.Methods)
+		} else {
+			m.Methods = nil // any method
+		}
+		if m.Name == "" {
+			m.Name = o.Name
+		}
+		m.Implicit = m.Implicit && o.Implicit
+		for _, v := range o.Middleware {
+			if !slices.Contains(m.Middleware, v) {
+				m.Middleware = append(m.Middleware, v)
+			}
+		}
+	}
+
+	// Global middleware is invoked before the route middleware
+	gm := middlewareNames(server.middleware)
+	result := make([]route.Route, 0, len(merged))
+	for _, o := range merged {
+		o.Middleware = append(append([]string{}, gm...), o.Middleware...)
+		result = append(result, *route.New(o))
+	}
+
+	// Mounted servers
+	for _, m := range server.mounts {
+		for _, o := range m.routes() {
+			o.Middleware = append(append([]string{}, gm...), o.Middleware...)
+			result = append(result, *route.New(o))
+		}
+	}
+	sort.Stable(route.ByRoutePath(result))
+
+	return result
 }
 
-// AddPage adds a page
-func (server *Server) AddPage(p *page.Page) error {
-	// Init vars
-	puf := p.URLPath()
+// Use adds the given middleware to the global middleware chain
+// Global middleware wraps every request (including not found ones) and it should be added before listening.
+func (server *Server) Use(mw ...Middleware) {
+	server.routesMu.Lock()
+	defer server.routesMu.Unlock()
+
+	server.middleware = append(server.middleware, mw...)
+	server.handler = nil
+}
 
-	if puf == "" {
-		return errors.New("invalid page url")
+// Handler returns the HTTP handler of the server
+// It serves the routes with the global middleware and the error responses exactly as Listen does,
+// so the server can be used by httptest.NewServer, mounted inside another mux or wrapped by other middleware.
+func (server *Server) Handler() http.Handler {
+	server.routesMu.RLock()
+	handler := server.handler
+	server.routesMu.RUnlock()
+	if handler != nil {
+		return handler
 	}
 
-	if server.pathRoot != "" {
-		puf = fmt.Sprintf("%s%s", server.pathRoot, strings.TrimLeft(puf, "/"))
+	server.routesMu.Lock()
+	defer server.routesMu.Unlock()
+
+	if server.handler == nil {
+		server.handler = clientIdentity(server.withPathPrefix(bodyLimit(server.maxBodyBytes, chain(server.middleware, http.HandlerFunc(server.routeHost)))))
 	}
-	ts := strings.HasSuffix(puf, "/")
+	return server.handler
+}
+
+// withPathPrefix sets the path prefix and the URL builder of the server into the request context
+// The prefix of the mount point (see Mount) precedes the path prefix of the server, and the trusted
+// prefix of a reverse proxy (see Options.TrustForwardedPrefix) precedes both.
+func (server *Server) withPathPrefix(next http.Handler) http.Handler {
+	// Init vars
+	prefix := strings.TrimSuffix(server.pathRoot, "/")
+	ub := request.URLBuilder(server.URLFor)
 
-	// Define handler function
-	h := func(w http.ResponseWriter, r *http.Request) {
-		// If match all is false, page url has trailing slash and requested url path is not equal to page url then
-		if !p.MatchAll() && ts && puf != r.URL.Path {
-			request.New(request.Options{Request: r, Writer: w}).Reply(request.Error{
-				StatusCode: 404,
-			})
-			return
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := &serverContext{Context: r.Context(), prefix: prefix, urlBuilder: ub}
+
+		// The forwarded prefix of a mounted server is inherited from the outer server
+		fp, ok := r.Context().Value(forwardedPrefixKey).(string)
+		if !ok && server.trustForwarded {
+			fp = forwardedPrefix(r.Header.Get("X-Forwarded-Prefix"))
 		}
+		ctx.forwarded = fp
+
+		mp, _ := r.Context().Value(mountPrefixKey).(string)
+		if base := fp + mp; base != "" {
+			ctx.prefix = base + prefix
+			ctx.urlBuilder = func(name string, params ...interface{}) (string, error) {
+				result, err := server.URLFor(name, params...)
+				if err != nil {
+					return "", err
+				}
+				return base + result, nil
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		// Execute the template and write into response
-		if err := p.TemplateExecute(w, nil); err != nil {
-			request.New(request.Options{Request: r, Writer: w}).Reply(request.Error{
-				StatusCode: 500,
-				Internal:   err,
-			})
-			return
+// forwardedPrefix returns the given X-Forwarded-Prefix header value if it's a valid path prefix
+// Values that are not absolute paths, or contain backslashes or control characters are ignored
+// so the prefix can't be used for building links to other hosts (i.e. "//evil.com" or "/\evil.com").
+func forwardedPrefix(v string) string {
+	v = strings.TrimRight(v, "/")
+	if !strings.HasPrefix(v, "/") || strings.HasPrefix(v, "//") {
+		return ""
+	}
+	for _, c := range v {
+		if c == '\\' || c < 0x20 || c == 0x7f {
+			return ""
 		}
 	}
-	server.AddHandlerFunc(p.URLPath(), h)
+	return v
+}
 
-	return nil
+// serverContext represents a request context that holds the path prefix and the URL builder of a server
+// It's used instead of nested context values for saving allocations on every request.
+type serverContext struct {
+	context.Context
+	prefix     string
+	forwarded  string
+	urlBuilder request.URLBuilder
+}
+
+// Value returns the value of the given key
+func (ctx *serverContext) Value(key interface{}) interface{} {
+	switch key {
+	case request.ContextKeys.PathPrefix:
+		return ctx.prefix
+	case request.ContextKeys.URLBuilder:
+		return ctx.urlBuilder
+	case forwardedPrefixKey:
+		return ctx.forwarded
+	}
+	return ctx.Context.Value(key)
+}
+
+// ServeHTTP serves the given request by the server handler (see Handler)
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.Handler().ServeHTTP(w, r)
 }
 
-// AddPages adds pages
-func (server *Server) AddPages(p ...*page.Page) error {
-	// Iterate over pages
-	for _, v := range p {
-		if err := server.AddPage(v); err != nil {
-			return err
+// handle registers the given handler for the given method and mux pattern
+func (server *Server) handle(method, host, pattern, prefix string, handler http.Handler, kind route.Kind, mw []Middleware) *Registration {
+	server.routesMu.Lock()
+	defer server.routesMu.Unlock()
+
+	// Register the handler on the endpoint of the pattern
+	// Conflicts are reported instead of registering the route (see Err).
+	reg := &Registration{server: server, key: strings.TrimSpace(method + " " + host + pattern)}
+	m := server.hostMux(host)
+	e, err := m.endpoint(pattern)
+	if err == nil {
+		err = e.add(method, reg.withName(chain(mw, handler)))
+	}
+	if err != nil {
+		reg.err = fmt.Errorf("failed to add route due to %s", err.Error())
+		server.routeErrs = append(server.routeErrs, reg.err)
+		log.Logger.Error("failed to add route", "server", server.id, "method", method, "pattern", host+pattern, "error", err)
+		return reg
+	}
+
+	// Add the route definition
+	ro := route.Options{
+		Path:       pattern,
+		Host:       host,
+		Kind:       kind,
+		Prefix:     prefix,
+		Middleware: middlewareNames(mw),
+	}
+	if method != "" {
+		ro.Methods = []string{method}
+	}
+	server.routes[reg.key] = ro
+
+	// If the pattern is a subtree then the path without the trailing slash
+	// redirects to it unless it's registered explicitly (same as http.ServeMux)
+	if strings.HasSuffix(pattern, "/") {
+		path := strings.TrimSuffix(pattern, "/")
+		if _, ok := m.patterns[path]; !ok {
+			server.routes[host+path] = route.Options{
+				Path:     path,
+				Pattern:  pattern,
+				Host:     host,
+				Kind:     route.KindRedirect,
+				Prefix:   prefix,
+				Implicit: true,
+			}
 		}
+	} else if r, ok := server.routes[host+pattern]; ok && r.Implicit {
+		// Otherwise remove the implicit redirect route since the path is registered explicitly
+		delete(server.routes, host+pattern)
 	}
 
-	return nil
+	return reg
 }
@@ -6,20 +6,30 @@
 package server_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"github.com/devfacet/goweb/log"
 	"github.com/devfacet/goweb/page"
+	"github.com/devfacet/goweb/request"
 	"github.com/devfacet/goweb/server"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+func init() {
+	// Disable the logs of the tests
+	log.Logger = log.New(log.Options{Handler: log.Discard})
+}
+
 func TestNew(t *testing.T) {
 	Convey("should create a new server", t, func() {
 		s := server.New(server.Options{})
@@ -48,6 +58,8 @@ func TestListenAll(t *testing.T) {
 			server.ListenAll(servers...)
 		}()
 		wg.Wait()
+		<-servers[0].Ready()
+		<-servers[1].Ready()
 
 		resp, err := http.Get(fmt.Sprintf("http://%s/foo", servers[0].Address()))
 		So(err, ShouldBeNil)
@@ -98,6 +110,15 @@ func TestAddress(t *testing.T) {
 		s := server.New(server.Options{Address: "localhost:3000"})
 		So(s.Address(), ShouldEqual, "localhost:3000")
 	})
+
+	Convey("should return the bound address", t, func() {
+		s := server.New(server.Options{})
+		So(s.Address(), ShouldEqual, "localhost:0")
+		So(s.Start(), ShouldBeNil)
+		So(s.Address(), ShouldStartWith, "localhost:")
+		So(s.Address(), ShouldNotEqual, "localhost:0")
+		s.Close()
+	})
 }
 
 func TestPathRoot(t *testing.T) {
@@ -133,6 +154,7 @@ func TestListen(t *testing.T) {
 			s.Listen()
 		}()
 		wg.Wait()
+		<-s.Ready()
 
 		resp, err := http.Get(fmt.Sprintf("http://%s/test", s.Address()))
 		So(err, ShouldBeNil)
@@ -149,6 +171,32 @@ func TestListen(t *testing.T) {
 	})
 }
 
+func TestStart(t *testing.T) {
+	Convey("should start the server in the background", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/test", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("test")) })
+		So(s.Start(), ShouldBeNil)
+		<-s.Ready()
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/test", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "test")
+
+		So(s.Start(), ShouldBeError, "server is already started")
+		s.Close()
+	})
+
+	Convey("should fail to start due to address in use", t, func() {
+		s1 := server.New(server.Options{})
+		So(s1.Start(), ShouldBeNil)
+		s2 := server.New(server.Options{Address: s1.Address()})
+		So(s2.Start(), ShouldNotBeNil)
+		s1.Close()
+	})
+}
+
 func TestClose(t *testing.T) {
 	Convey("should close listeners and connections", t, func() {
 		s := server.New(server.Options{})
@@ -177,6 +225,100 @@ func TestShutdown(t *testing.T) {
 	})
 }
 
+func TestShutdownContext(t *testing.T) {
+	Convey("should wait for in-flight requests and invoke the shutdown hooks", t, func() {
+		s := server.New(server.Options{ShutdownTimeout: time.Second})
+		started := make(chan struct{})
+		s.AddGet("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("slow"))
+		})
+		hooked := make(chan context.Context, 1)
+		s.OnShutdown(func(ctx context.Context) {
+			hooked <- ctx
+		})
+		So(s.Start(), ShouldBeNil)
+		<-s.Ready()
+
+		result := make(chan string, 1)
+		go func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s/slow", s.Address()))
+			if err != nil {
+				result <- err.Error()
+				return
+			}
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			result <- string(b)
+		}()
+		<-started
+		So(s.Shutdown(), ShouldBeNil)
+		So(<-result, ShouldEqual, "slow")
+		So(<-hooked, ShouldNotBeNil)
+		So(s.State(), ShouldEqual, server.StateStopped)
+	})
+
+	Convey("should close the remaining connections when the deadline is exceeded", t, func() {
+		s := server.New(server.Options{ShutdownTimeout: 20 * time.Millisecond})
+		started := make(chan struct{})
+		release := make(chan struct{})
+		s.AddGet("/hang", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+		So(s.Start(), ShouldBeNil)
+		<-s.Ready()
+
+		result := make(chan error, 1)
+		go func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s/hang", s.Address()))
+			if err == nil {
+				resp.Body.Close()
+			}
+			result <- err
+		}()
+		<-started
+		So(s.Shutdown(), ShouldEqual, context.DeadlineExceeded)
+		So(<-result, ShouldNotBeNil)
+		close(release)
+	})
+}
+
+func TestRun(t *testing.T) {
+	Convey("should run the server until the context is done", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/test", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("test")) })
+		hooked := make(chan struct{})
+		s.OnShutdown(func(ctx context.Context) { close(hooked) })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() {
+			result <- s.Run(ctx)
+		}()
+		<-s.Ready()
+		resp, err := http.Get(fmt.Sprintf("http://%s/test", s.Address()))
+		So(err, ShouldBeNil)
+		resp.Body.Close()
+
+		cancel()
+		select {
+		case err := <-result:
+			So(err, ShouldBeNil)
+		case <-time.After(time.Second):
+			So("timeout", ShouldBeNil)
+		}
+		<-hooked
+		So(s.State(), ShouldEqual, server.StateStopped)
+	})
+
+	Convey("should return the start error", t, func() {
+		s := server.New(server.Options{Address: "localhost"})
+		So(s.Run(context.Background()), ShouldNotBeNil)
+	})
+}
+
 func TestRoutes(t *testing.T) {
 	Convey("should return list of routes", t, func() {
 		s := server.New(server.Options{})
@@ -435,6 +577,7 @@ func TestAddPage(t *testing.T) {
 			s.Listen()
 		}()
 		wg.Wait()
+		<-s.Ready()
 
 		resp, err := http.Get(fmt.Sprintf("http://%s/foo", s.Address()))
 		So(err, ShouldBeNil)
@@ -447,8 +590,8 @@ func TestAddPage(t *testing.T) {
 		defer resp.Body.Close()
 		b, _ = ioutil.ReadAll(resp.Body)
 		So(resp.StatusCode, ShouldEqual, 404)
-		So(resp.Header.Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
-		So(strings.TrimSpace(string(b)), ShouldEqual, "404 page not found")
+		So(resp.Header.Get("Content-Type"), ShouldEqual, "application/json")
+		So(string(b), ShouldEqual, `{"statusCode":404,"message":"Not Found"}`)
 
 		s.Close()
 	})
@@ -471,6 +614,7 @@ func TestAddPage(t *testing.T) {
 			s.Listen()
 		}()
 		wg.Wait()
+		<-s.Ready()
 
 		resp, err := http.Get(fmt.Sprintf("http://%s/foo", s.Address()))
 		So(err, ShouldBeNil)
@@ -515,6 +659,7 @@ func TestAddPage(t *testing.T) {
 			s.Listen()
 		}()
 		wg.Wait()
+		<-s.Ready()
 
 		resp, err = http.Get(fmt.Sprintf("http://%s/foo", s.Address()))
 		So(err, ShouldBeNil)
@@ -527,16 +672,16 @@ func TestAddPage(t *testing.T) {
 		defer resp.Body.Close()
 		b, _ = ioutil.ReadAll(resp.Body)
 		So(resp.StatusCode, ShouldEqual, 404)
-		So(resp.Header.Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
-		So(strings.TrimSpace(string(b)), ShouldEqual, "404 page not found")
+		So(resp.Header.Get("Content-Type"), ShouldEqual, "application/json")
+		So(string(b), ShouldEqual, `{"statusCode":404,"message":"Not Found"}`)
 
 		resp, err = http.Get(fmt.Sprintf("http://%s/foo/bar", s.Address()))
 		So(err, ShouldBeNil)
 		defer resp.Body.Close()
 		b, _ = ioutil.ReadAll(resp.Body)
 		So(resp.StatusCode, ShouldEqual, 404)
-		So(resp.Header.Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
-		So(strings.TrimSpace(string(b)), ShouldEqual, "404 page not found")
+		So(resp.Header.Get("Content-Type"), ShouldEqual, "application/json")
+		So(string(b), ShouldEqual, `{"statusCode":404,"message":"Not Found"}`)
 
 		s.Close()
 	})
@@ -565,6 +710,7 @@ func TestAddPage(t *testing.T) {
 			s.Listen()
 		}()
 		wg.Wait()
+		<-s.Ready()
 
 		resp, err := http.Get(fmt.Sprintf("http://%s/", s.Address()))
 		So(err, ShouldBeNil)
@@ -606,6 +752,7 @@ func TestAddPage(t *testing.T) {
 			s.Listen()
 		}()
 		wg.Wait()
+		<-s.Ready()
 
 		resp, err := http.Get(fmt.Sprintf("http://%s/foo", s.Address()))
 		So(err, ShouldBeNil)
@@ -646,3 +793,397 @@ func TestAddPages(t *testing.T) {
 		So(s.AddPages(pages...), ShouldBeError, errors.New("invalid page url"))
 	})
 }
+
+func TestAddMethodHandlerFunc(t *testing.T) {
+	Convey("should add handlers by method", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("get")) })
+		s.AddPost("/foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("post")) })
+		s.AddHandlerFunc("DELETE /foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("delete")) })
+		s.AddHandlerFunc("/bar", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.Method)) })
+		rl := s.Routes()
+		So(len(rl), ShouldEqual, 2)
+		So(rl[0].Path(), ShouldEqual, "/bar")
+		So(rl[0].Methods(), ShouldBeEmpty)
+		So(rl[1].Path(), ShouldEqual, "/foo")
+		So(rl[1].Methods(), ShouldResemble, []string{"DELETE", "GET", "POST"})
+
+		So(s.Start(), ShouldBeNil)
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/foo", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "get")
+
+		resp, err = http.Post(fmt.Sprintf("http://%s/foo", s.Address()), "text/plain", nil)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "post")
+
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("http://%s/foo", s.Address()), nil)
+		resp, err = http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "delete")
+
+		req, _ = http.NewRequest("PUT", fmt.Sprintf("http://%s/foo", s.Address()), nil)
+		resp, err = http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(resp.StatusCode, ShouldEqual, 405)
+		So(resp.Header.Get("Allow"), ShouldEqual, "DELETE, GET, HEAD, OPTIONS, POST")
+		So(resp.Header.Get("Content-Type"), ShouldEqual, "application/json")
+		So(strings.TrimSpace(string(b)), ShouldEqual, `{"statusCode":405,"message":"Method Not Allowed"}`)
+
+		req, _ = http.NewRequest("OPTIONS", fmt.Sprintf("http://%s/foo", s.Address()), nil)
+		resp, err = http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, 204)
+		So(resp.Header.Get("Allow"), ShouldEqual, "DELETE, GET, HEAD, OPTIONS, POST")
+
+		req, _ = http.NewRequest("PUT", fmt.Sprintf("http://%s/bar", s.Address()), nil)
+		resp, err = http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "PUT")
+
+		s.Close()
+	})
+}
+
+func TestPathParams(t *testing.T) {
+	Convey("should serve routes with path parameters", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			req := request.New(request.Options{Request: r, Writer: w})
+			id, err := req.ParamInt("id")
+			if err != nil {
+				return
+			}
+			req.Reply(fmt.Sprintf("user %d", id))
+		})
+		s.AddGet("/users/new", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("new")) })
+		s.AddGet("/reports/{name}/rows/{n}", func(w http.ResponseWriter, r *http.Request) {
+			req := request.New(request.Options{Request: r, Writer: w})
+			req.Reply(req.Param("name") + ":" + req.Param("n"))
+		})
+		s.AddGet("/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(request.New(request.Options{Request: r}).Param("path")))
+		})
+		p, err := page.New(page.Options{URLPath: "/docs/{slug}", Content: `{{param "slug"}} {{.slug}}`})
+		So(err, ShouldBeNil)
+		So(s.AddPage(p), ShouldBeNil)
+
+		So(s.Start(), ShouldBeNil)
+
+		for _, v := range []struct {
+			path   string
+			status int
+			body   string
+		}{
+			{"/users/1", 200, "user 1"},
+			{"/users/new", 200, "new"},
+			{"/users/foo", 400, `{"statusCode":400,"message":"invalid path parameter id"}`},
+			{"/users/1/foo", 404, `{"statusCode":404,"message":"Not Found"}`},
+			{"/reports/daily/rows/5", 200, "daily:5"},
+			{"/files/a/b/c.txt", 200, "a/b/c.txt"},
+			{"/docs/intro", 200, "intro intro"},
+		} {
+			resp, err := http.Get(fmt.Sprintf("http://%s%s", s.Address(), v.path))
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			So(resp.StatusCode, ShouldEqual, v.status)
+			So(strings.TrimSpace(string(b)), ShouldEqual, v.body)
+		}
+
+		s.Close()
+	})
+
+	Convey("should fail to add a page due to invalid pattern", t, func() {
+		s := server.New(server.Options{})
+		p, err := page.New(page.Options{URLPath: "/docs/{slug...}/foo"})
+		So(err, ShouldBeNil)
+		So(s.AddPage(p), ShouldBeError, errors.New("invalid pattern /docs/{slug...}/foo due to wildcard segment is not at the end"))
+	})
+}
+
+func testMiddleware(name string) server.Middleware {
+	return server.Named(name, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", name)
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+func TestUse(t *testing.T) {
+	Convey("should invoke global and route middleware in order", t, func() {
+		s := server.New(server.Options{})
+		s.Use(testMiddleware("global1"), testMiddleware("global2"))
+		s.AddGet("/foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("foo")) }, testMiddleware("route"))
+		p, err := page.New(page.Options{URLPath: "/bar", Content: "bar"})
+		So(err, ShouldBeNil)
+		So(s.AddPage(p, testMiddleware("page")), ShouldBeNil)
+
+		s.AddGet("/zip/", func(w http.ResponseWriter, r *http.Request) {}, server.Compress(server.CompressOptions{}))
+
+		rl := s.Routes()
+		So(len(rl), ShouldEqual, 4)
+		So(rl[0].Middleware(), ShouldResemble, []string{"global1", "global2", "page"})
+		So(rl[1].Middleware(), ShouldResemble, []string{"global1", "global2", "route"})
+		So(rl[3].Middleware(), ShouldResemble, []string{"global1", "global2", "server.Compress"})
+
+		So(s.Start(), ShouldBeNil)
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/foo", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(strings.Join(resp.Header["X-Middleware"], ","), ShouldEqual, "global1,global2,route")
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/bar", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "bar")
+		So(strings.Join(resp.Header["X-Middleware"], ","), ShouldEqual, "global1,global2,page")
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/baz", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, 404)
+		So(strings.Join(resp.Header["X-Middleware"], ","), ShouldEqual, "global1,global2")
+
+		s.Close()
+	})
+}
+
+func TestGroup(t *testing.T) {
+	Convey("should add routes by groups", t, func() {
+		s := server.New(server.Options{PathPrefix: "app"})
+		api := s.Group("/api/v1", testMiddleware("api"))
+		api.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(request.New(request.Options{Request: r}).Param("id")))
+		})
+		admin := api.Group("admin/", testMiddleware("admin"))
+		admin.AddGet("stats", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("stats")) }, testMiddleware("route"))
+		p, err := page.New(page.Options{URLPath: "/", Content: "home"})
+		So(err, ShouldBeNil)
+		So(s.Group("").AddPage(p), ShouldBeNil)
+		So(api.Prefix(), ShouldEqual, "/app/api/v1/")
+		So(admin.Prefix(), ShouldEqual, "/app/api/v1/admin/")
+
+		rl := s.Routes()
+		So(len(rl), ShouldEqual, 4)
+		So(rl[0].Path(), ShouldEqual, "/app")
+		So(rl[0].Redirect(), ShouldEqual, true)
+		So(rl[1].Path(), ShouldEqual, "/app/")
+		So(rl[1].Prefix(), ShouldEqual, "/app/")
+		So(rl[2].Path(), ShouldEqual, "/app/api/v1/admin/stats")
+		So(rl[2].Prefix(), ShouldEqual, "/app/api/v1/admin/")
+		So(len(rl[2].Middleware()), ShouldEqual, 3)
+		So(rl[3].Path(), ShouldEqual, "/app/api/v1/users/{id}")
+		So(rl[3].Prefix(), ShouldEqual, "/app/api/v1/")
+		So(len(rl[3].Middleware()), ShouldEqual, 1)
+
+		So(s.Start(), ShouldBeNil)
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/app/api/v1/users/1", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "1")
+		So(strings.Join(resp.Header["X-Middleware"], ","), ShouldEqual, "api")
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/app/api/v1/admin/stats", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "stats")
+		So(strings.Join(resp.Header["X-Middleware"], ","), ShouldEqual, "api,admin,route")
+
+		resp, err = http.Get(fmt.Sprintf("http://%s/app/", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "home")
+
+		s.Close()
+	})
+}
+
+func TestHandler(t *testing.T) {
+	Convey("should serve the routes by httptest", t, func() {
+		s := server.New(server.Options{PathPrefix: "app"})
+		s.Use(testMiddleware("global"))
+		s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(request.New(request.Options{Request: r}).Param("id")))
+		}, testMiddleware("route"))
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/app/users/1")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "1")
+		So(strings.Join(resp.Header["X-Middleware"], ","), ShouldEqual, "global,route")
+
+		resp, err = http.Get(ts.URL + "/users/1")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusNotFound)
+		So(strings.Join(resp.Header["X-Middleware"], ","), ShouldEqual, "global")
+
+		resp, err = http.Post(ts.URL+"/app/users/1", "text/plain", nil)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		So(resp.StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+	})
+
+	Convey("should be mounted inside another mux", t, func() {
+		s := server.New(server.Options{PathPrefix: "app"})
+		s.AddGet("/foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("foo")) })
+		s.Use(testMiddleware("global"))
+		mux := http.NewServeMux()
+		mux.Handle("/app/", s.Handler())
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/app/foo", nil))
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, "foo")
+		So(w.Header().Get("X-Middleware"), ShouldEqual, "global")
+	})
+
+	Convey("should add routes while serving", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("foo")) })
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 50; i++ {
+				s.AddGet(fmt.Sprintf("/bar/%d/{id}", i), func(w http.ResponseWriter, r *http.Request) {}).Name(fmt.Sprintf("bar%d", i))
+			}
+		}()
+		codes := map[int]int{}
+		for i := 0; i < 50; i++ {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/bar/%d/1", i), nil))
+			codes[w.Code]++
+			w = httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+			So(w.Body.String(), ShouldEqual, "foo")
+		}
+		<-done
+		So(codes[http.StatusOK]+codes[http.StatusNotFound], ShouldEqual, 50)
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/bar/49/1", nil))
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("should not reply the errors as JSONP", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("foo")) })
+
+		for _, v := range []struct {
+			method string
+			path   string
+			code   int
+			body   string
+		}{
+			{"GET", "/nope?callback=alert(document.domain)//", 404, `{"statusCode":404,"message":"Not Found"}`},
+			{"POST", "/foo?callback=alert(document.domain)//", 405, `{"statusCode":405,"message":"Method Not Allowed"}`},
+		} {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(v.method, v.path, nil))
+			So(w.Code, ShouldEqual, v.code)
+			So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+			So(w.Header().Get("X-Content-Type-Options"), ShouldEqual, "nosniff")
+			So(w.Body.String(), ShouldEqual, v.body)
+		}
+	})
+}
+
+func TestPathPrefix(t *testing.T) {
+	Convey("should set the path prefix into the request context", t, func() {
+		s := server.New(server.Options{PathPrefix: "app"})
+		s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(request.New(request.Options{Request: r}).URL("/users/2")))
+		})
+		p, err := page.New(page.Options{URLPath: "/", Content: `<a href="{{url "/users/1"}}">user</a>`})
+		So(err, ShouldBeNil)
+		So(s.AddPage(p), ShouldBeNil)
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/app/users/1")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "/app/users/2")
+
+		req, err := http.NewRequest("GET", ts.URL+"/app/", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("X-Forwarded-Prefix", "/dashboard")
+		resp, err = http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, `<a href="/app/users/1">user</a>`)
+	})
+
+	Convey("should honor the X-Forwarded-Prefix header only if it's trusted", t, func() {
+		s := server.New(server.Options{PathPrefix: "app", TrustForwardedPrefix: true})
+		s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(request.New(request.Options{Request: r}).URL("/users/2")))
+		})
+
+		for _, v := range []struct {
+			prefix string
+			url    string
+		}{
+			{"/dashboard/", "/dashboard/app/users/2"},
+			{"", "/app/users/2"},
+			{"dashboard", "/app/users/2"},
+			{"//evil.com", "/app/users/2"},
+			{"/\\evil.com", "/app/users/2"},
+			{"/dash\tboard", "/app/users/2"},
+		} {
+			r := httptest.NewRequest("GET", "/app/users/1", nil)
+			r.Header.Set("X-Forwarded-Prefix", v.prefix)
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			So(w.Body.String(), ShouldEqual, v.url)
+		}
+	})
+
+	Convey("should redirect with the trusted X-Forwarded-Prefix header", t, func() {
+		s := server.New(server.Options{TrustForwardedPrefix: true})
+		s.AddGet("/docs/", func(w http.ResponseWriter, r *http.Request) {})
+		s.AddStaticFS("/assets", fstest.MapFS{"nested/app.js": {Data: []byte("app")}}, server.StaticOptions{})
+
+		for _, v := range []struct {
+			path     string
+			location string
+		}{
+			{"/docs?v=1", "/app/docs/?v=1"},
+			{"/docs/../docs", "/app/docs"},
+			{"/assets/nested", "/app/assets/nested/"},
+		} {
+			r := httptest.NewRequest("GET", v.path, nil)
+			r.Header.Set("X-Forwarded-Prefix", "/app")
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			So(w.Code, ShouldEqual, http.StatusMovedPermanently)
+			So(w.Header().Get("Location"), ShouldEqual, v.location)
+		}
+	})
+}
@@ -0,0 +1,341 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devfacet/goweb/content"
+	"github.com/devfacet/goweb/route"
+)
+
+const (
+	defaultStaticIndex = "index.html"
+)
+
+var (
+	// staticEncodings holds the file extensions of the precompressed variants by the content encodings
+	staticEncodings = map[string]string{"br": ".br", "gzip": ".gz"}
+)
+
+// StaticOptions represents the options than can be set when serving static files
+type StaticOptions struct {
+	// Index holds the name of the index file of the directories (default "index.html")
+	Index string
+	// CacheControl holds the Cache-Control header value of the files (i.e. "public, max-age=3600")
+	CacheControl string
+	// CacheControlByExt holds the Cache-Control header values by the file extensions which override
+	// CacheControl (i.e. {".html": "no-cache", ".js": "public, max-age=31536000, immutable"})
+	CacheControlByExt map[string]string
+	// Listing enables the generated listings of the directories without an index file
+	// Listings are served as HTML, or as JSON for "application/json" requests and the format query
+	// parameter (i.e. "/assets/?format=json"). Directories are not listed by default.
+	Listing bool
+	// SPA serves the root index file for the unknown paths that don't have a file extension
+	// (i.e. "/app/users/1") so a single page application can route them on the client side.
+	SPA bool
+}
+
+// static represents a static file handler
+type static struct {
+	fs           http.FileSystem
+	prefix       string
+	index        string
+	cacheControl string
+	cacheByExt   map[string]string
+	listing      bool
+	spa          bool
+	etags        sync.Map // name > etag
+}
+
+// etag represents the ETag of a file version
+type etag struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+// listEntry represents an entry of a directory listing
+type listEntry struct {
+	Name    string    `json:"name"`
+	Dir     bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// AddStatic adds a handler that serves the files of the given file system under the given URL path
+// (i.e. `AddStatic("/assets", http.Dir("public"), StaticOptions{})`). Files are served for GET and
+// HEAD requests with strong ETags, conditional and range requests. The URL path is stripped from the
+// request path before opening the files so "/assets/app.js" serves "app.js" of the file system.
+// Precompressed variants of the files (i.e. "app.js.br" and "app.js.gz") are served by the
+// Accept-Encoding header of the request with the Content-Encoding and Vary headers.
+func (router *Router) AddStatic(urlPath string, fsys http.FileSystem, o StaticOptions, mw ...Middleware) *Registration {
+	// Init vars
+	pattern := router.pattern(urlPath)
+	if !strings.HasSuffix(pattern, "/") {
+		pattern += "/"
+	}
+	s := &static{
+		fs:           fsys,
+		prefix:       strings.TrimSuffix(pattern, "/"),
+		index:        o.Index,
+		cacheControl: o.CacheControl,
+		cacheByExt:   map[string]string{},
+		listing:      o.Listing,
+		spa:          o.SPA,
+	}
+	if s.index == "" {
+		s.index = defaultStaticIndex
+	}
+	for k, v := range o.CacheControlByExt {
+		s.cacheByExt[strings.ToLower(k)] = v
+	}
+
+	return router.handle(http.MethodGet, pattern, http.StripPrefix(s.prefix, s), route.KindStatic, mw)
+}
+
+// AddStaticFS adds a handler that serves the files of the given fs.FS under the given URL path
+// (i.e. an embed.FS, see AddStatic). Use fs.Sub for serving a subdirectory of the file system.
+func (router *Router) AddStaticFS(urlPath string, fsys fs.FS, o StaticOptions, mw ...Middleware) *Registration {
+	return router.AddStatic(urlPath, http.FS(fsys), o, mw...)
+}
+
+// ServeHTTP serves the file of the request path
+func (s *static) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Init vars
+	name := path.Clean("/" + r.URL.Path)
+
+	f, err := s.fs.Open(name)
+	if err != nil {
+		if s.spa && errors.Is(err, fs.ErrNotExist) && path.Ext(name) == "" {
+			if ok := s.serveFile(w, r, "/"+s.index); !ok {
+				replyError(w, http.StatusNotFound, "")
+			}
+			return
+		}
+		staticError(w, err)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		staticError(w, err)
+		return
+	}
+
+	if !fi.IsDir() {
+		s.serveContent(w, r, name, f, fi)
+		return
+	}
+
+	// Directories are served with a trailing slash so the relative links work
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		redirectPath(w, r, s.prefix+name+"/")
+		return
+	}
+	if ok := s.serveFile(w, r, path.Join(name, s.index)); ok {
+		return
+	}
+	if !s.listing {
+		replyError(w, http.StatusNotFound, "")
+		return
+	}
+	s.serveListing(w, r, name, f)
+}
+
+// serveFile serves the given file and returns whether the file exists or not
+func (s *static) serveFile(w http.ResponseWriter, r *http.Request, name string) bool {
+	f, err := s.fs.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false
+		}
+		staticError(w, err)
+		return true
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		staticError(w, err)
+		return true
+	} else if fi.IsDir() {
+		return false
+	}
+
+	s.serveContent(w, r, name, f, fi)
+	return true
+}
+
+// serveContent serves the content of the given file by the caching headers
+// A precompressed variant of the file (i.e. "app.js.br" or "app.js.gz") is served instead
+// of the file if it exists and its encoding is accepted by the request.
+func (s *static) serveContent(w http.ResponseWriter, r *http.Request, name string, f http.File, fi fs.FileInfo) {
+	if cc, ok := s.cacheByExt[strings.ToLower(path.Ext(name))]; ok {
+		w.Header().Set("Cache-Control", cc)
+	} else if s.cacheControl != "" {
+		w.Header().Set("Cache-Control", s.cacheControl)
+	}
+
+	// Precompressed variants
+	encoding, vf, vfi, vary := s.variant(r, name)
+	if vary {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if vf != nil {
+		defer vf.Close()
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			// Detect the content type by the original content
+			b := make([]byte, 512)
+			n, _ := io.ReadFull(f, b)
+			ctype = http.DetectContentType(b[:n])
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Content-Encoding", encoding)
+		name, f, fi = name+staticEncodings[encoding], vf, vfi
+	}
+
+	tag, err := s.etag(name, f, fi)
+	if err != nil {
+		staticError(w, err)
+		return
+	}
+	w.Header().Set("ETag", tag)
+
+	// ServeContent handles the conditional and range requests
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+// variant returns the precompressed variant of the given file that is accepted by the request
+// and whether the file has any precompressed variant or not.
+func (s *static) variant(r *http.Request, name string) (string, http.File, fs.FileInfo, bool) {
+	// Init vars
+	available := []string{}
+	vary := false
+
+	// Find the existing variants
+	for _, v := range []string{"br", "gzip"} {
+		f, err := s.fs.Open(name + staticEncodings[v])
+		if err != nil {
+			continue
+		}
+		fi, err := f.Stat()
+		f.Close()
+		if err == nil && !fi.IsDir() {
+			vary = true
+			available = append(available, v)
+		}
+	}
+	encoding := content.NegotiateEncoding(r.Header.Get("Accept-Encoding"), available...)
+	if encoding == "" {
+		return "", nil, nil, vary
+	}
+
+	// Open the preferred variant
+	f, err := s.fs.Open(name + staticEncodings[encoding])
+	if err != nil {
+		return "", nil, nil, vary
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return "", nil, nil, vary
+	}
+
+	return encoding, f, fi, vary
+}
+
+// etag returns the strong ETag of the given file
+// ETags are the content hashes which are cached until the file is modified.
+func (s *static) etag(name string, f http.File, fi fs.FileInfo) (string, error) {
+	if v, ok := s.etags.Load(name); ok {
+		if e := v.(etag); e.modTime.Equal(fi.ModTime()) && e.size == fi.Size() {
+			return e.value, nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	e := etag{modTime: fi.ModTime(), size: fi.Size(), value: `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`}
+	s.etags.Store(name, e)
+
+	return e.value, nil
+}
+
+// serveListing serves the listing of the given directory
+func (s *static) serveListing(w http.ResponseWriter, r *http.Request, name string, f http.File) {
+	// Init vars
+	fl, err := f.Readdir(-1)
+	if err != nil {
+		staticError(w, err)
+		return
+	}
+	entries := make([]listEntry, 0, len(fl))
+	for _, v := range fl {
+		e := listEntry{Name: v.Name(), Dir: v.IsDir(), Size: v.Size(), ModTime: v.ModTime().UTC()}
+		if e.Dir {
+			e.Name += "/"
+			e.Size = 0
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	// JSON
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+		return
+	}
+
+	// HTML
+	var b strings.Builder
+	title := html.EscapeString("Index of " + name)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n<h1>%s</h1>\n<ul>\n", title, title)
+	if name != "/" {
+		b.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, v := range entries {
+		href := (&url.URL{Path: v.Name}).String()
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href), html.EscapeString(v.Name))
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, b.String())
+}
+
+// staticError writes the response of the given file system error
+func staticError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		replyError(w, http.StatusNotFound, "")
+	case errors.Is(err, fs.ErrPermission):
+		replyError(w, http.StatusForbidden, "")
+	default:
+		replyError(w, http.StatusInternalServerError, "")
+	}
+}
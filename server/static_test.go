@@ -0,0 +1,212 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/devfacet/goweb/route"
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// testStaticDir returns a directory with static files
+func testStaticDir(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":        "<h1>app</h1>",
+		"app.js":            "console.log('app')",
+		"docs/guide.txt":    "guide",
+		"docs/api/ref.txt":  "ref",
+		"empty/.keep":       "",
+		"docs/a b&c.txt":    "escaped",
+		"nested/index.html": "<h1>nested</h1>",
+	}
+	for k, v := range files {
+		p := filepath.Join(dir, filepath.FromSlash(k))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(v), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// testServe serves the given request by the given server
+func testServe(s *server.Server, method, target string, header map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestAddStatic(t *testing.T) {
+	dir := testStaticDir(t)
+
+	Convey("should serve the static files", t, func() {
+		s := server.New(server.Options{})
+		reg := s.AddStatic("/assets", http.Dir(dir), server.StaticOptions{
+			CacheControl:      "public, max-age=3600",
+			CacheControlByExt: map[string]string{".HTML": "no-cache"},
+		})
+		So(reg.Err(), ShouldBeNil)
+
+		w := testServe(s, "GET", "/assets/app.js", nil)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, "console.log('app')")
+		So(w.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=3600")
+		So(w.Header().Get("Content-Type"), ShouldContainSubstring, "javascript")
+		tag := w.Header().Get("ETag")
+		So(tag, ShouldStartWith, `"`)
+		So(len(tag), ShouldEqual, 34)
+
+		w = testServe(s, "GET", "/assets/app.js", map[string]string{"If-None-Match": tag})
+		So(w.Code, ShouldEqual, http.StatusNotModified)
+
+		w = testServe(s, "GET", "/assets/app.js", map[string]string{"Range": "bytes=0-6", "If-Range": tag})
+		So(w.Code, ShouldEqual, http.StatusPartialContent)
+		So(w.Body.String(), ShouldEqual, "console")
+
+		w = testServe(s, "HEAD", "/assets/app.js", nil)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("ETag"), ShouldEqual, tag)
+
+		w = testServe(s, "GET", "/assets/", nil)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, "<h1>app</h1>")
+		So(w.Header().Get("Cache-Control"), ShouldEqual, "no-cache")
+
+		w = testServe(s, "GET", "/assets/nested?v=1", nil)
+		So(w.Code, ShouldEqual, http.StatusMovedPermanently)
+		So(w.Header().Get("Location"), ShouldEqual, "/assets/nested/?v=1")
+
+		w = testServe(s, "GET", "/assets/docs/", nil)
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+
+		w = testServe(s, "GET", "/assets/missing", nil)
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+		So(w.Body.String(), ShouldEqual, `{"statusCode":404,"message":"Not Found"}`)
+
+		w = testServe(s, "GET", "/assets/../server.go", nil)
+		So(w.Code, ShouldNotEqual, http.StatusOK)
+
+		w = testServe(s, "POST", "/assets/app.js", nil)
+		So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+		So(w.Body.String(), ShouldEqual, `{"statusCode":405,"message":"Method Not Allowed"}`)
+
+		rl := s.Routes()
+		So(rl[1].Pattern(), ShouldEqual, "/assets/")
+		So(rl[1].Kind(), ShouldEqual, route.KindStatic)
+	})
+
+	Convey("should serve the directory listings", t, func() {
+		s := server.New(server.Options{})
+		s.AddStatic("/files/", http.Dir(dir), server.StaticOptions{Listing: true})
+
+		w := testServe(s, "GET", "/files/docs/", nil)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+		So(w.Body.String(), ShouldContainSubstring, "<title>Index of /docs</title>")
+		So(w.Body.String(), ShouldContainSubstring, `<li><a href="../">../</a></li>`)
+		So(w.Body.String(), ShouldContainSubstring, `<li><a href="a%20b&amp;c.txt">a b&amp;c.txt</a></li>`)
+		So(w.Body.String(), ShouldContainSubstring, `<li><a href="api/">api/</a></li>`)
+
+		w = testServe(s, "GET", "/files/docs/", map[string]string{"Accept": "application/json"})
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		var entries []map[string]interface{}
+		So(json.Unmarshal(w.Body.Bytes(), &entries), ShouldBeNil)
+		So(len(entries), ShouldEqual, 3)
+		So(entries[0]["name"], ShouldEqual, "a b&c.txt")
+		So(entries[1]["name"], ShouldEqual, "api/")
+		So(entries[1]["dir"], ShouldBeTrue)
+		So(entries[2]["size"], ShouldEqual, 5)
+
+		w = testServe(s, "GET", "/files/nested/", map[string]string{"Accept": "application/json"})
+		So(w.Body.String(), ShouldEqual, "<h1>nested</h1>")
+	})
+
+	Convey("should serve the index file for the unknown paths in SPA mode", t, func() {
+		s := server.New(server.Options{})
+		s.AddStatic("/", http.Dir(dir), server.StaticOptions{SPA: true})
+
+		w := testServe(s, "GET", "/users/1", nil)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, "<h1>app</h1>")
+
+		w = testServe(s, "GET", "/app.js", nil)
+		So(w.Body.String(), ShouldEqual, "console.log('app')")
+
+		w = testServe(s, "GET", "/missing.js", nil)
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+
+		s = server.New(server.Options{})
+		s.AddStatic("/", http.Dir(filepath.Join(dir, "docs")), server.StaticOptions{SPA: true})
+		w = testServe(s, "GET", "/users/1", nil)
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+}
+
+func TestAddStaticFS(t *testing.T) {
+	Convey("should serve the files of the given fs.FS with the precompressed variants", t, func() {
+		fsys := fstest.MapFS{
+			"app.js":       &fstest.MapFile{Data: []byte("console.log('app')")},
+			"app.js.gz":    &fstest.MapFile{Data: []byte("gzip")},
+			"app.js.br":    &fstest.MapFile{Data: []byte("brotli")},
+			"style.css":    &fstest.MapFile{Data: []byte("body{}")},
+			"style.css.gz": &fstest.MapFile{Data: []byte("gzip")},
+			"data":         &fstest.MapFile{Data: []byte("<html></html>")},
+			"data.gz":      &fstest.MapFile{Data: []byte("gzip")},
+		}
+		s := server.New(server.Options{})
+		So(s.AddStaticFS("/assets", fsys, server.StaticOptions{CacheControl: "public, max-age=60"}).Err(), ShouldBeNil)
+
+		w := testServe(s, "GET", "/assets/app.js", nil)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, "console.log('app')")
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+		tag := w.Header().Get("ETag")
+
+		w = testServe(s, "GET", "/assets/app.js", map[string]string{"Accept-Encoding": "gzip, deflate, br"})
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Body.String(), ShouldEqual, "brotli")
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "br")
+		So(w.Header().Get("Content-Type"), ShouldContainSubstring, "javascript")
+		So(w.Header().Get("Cache-Control"), ShouldEqual, "public, max-age=60")
+		So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+		So(w.Header().Get("ETag"), ShouldNotEqual, tag)
+
+		w = testServe(s, "GET", "/assets/app.js", map[string]string{"Accept-Encoding": "gzip, br;q=0"})
+		So(w.Body.String(), ShouldEqual, "gzip")
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+
+		w = testServe(s, "GET", "/assets/style.css", map[string]string{"Accept-Encoding": "br"})
+		So(w.Body.String(), ShouldEqual, "body{}")
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+
+		w = testServe(s, "GET", "/assets/data", map[string]string{"Accept-Encoding": "gzip"})
+		So(w.Body.String(), ShouldEqual, "gzip")
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+
+		w = testServe(s, "GET", "/assets/app.js.gz", map[string]string{"Accept-Encoding": "gzip"})
+		So(w.Body.String(), ShouldEqual, "gzip")
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "")
+		So(w.Header().Get("Vary"), ShouldEqual, "")
+	})
+}
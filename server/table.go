@@ -0,0 +1,54 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/devfacet/goweb/route"
+)
+
+// AddRouteTable adds a handler that serves the route table of the server for GET requests
+// (i.e. `AddRouteTable("/_routes", auth)`). The route table is served as JSON by default, as a text
+// table for "text/plain" and as Markdown for "text/markdown" requests, or by the format query
+// parameter (i.e. "/_routes?format=markdown"). It's opt-in since the routes may reveal internal endpoints.
+func (router *Router) AddRouteTable(pattern string, mw ...Middleware) *Registration {
+	return router.AddGet(pattern, router.server.serveRouteTable, mw...)
+}
+
+// serveRouteTable writes the route table by the requested format
+func (server *Server) serveRouteTable(w http.ResponseWriter, r *http.Request) {
+	// Init vars
+	f := route.Format(strings.ToLower(r.URL.Query().Get("format")))
+	if f == "" {
+		accept := r.Header.Get("Accept")
+		switch {
+		case strings.Contains(accept, "text/markdown"):
+			f = route.FormatMarkdown
+		case strings.Contains(accept, "text/plain"):
+			f = route.FormatText
+		default:
+			f = route.FormatJSON
+		}
+	}
+
+	var b bytes.Buffer
+	if err := route.WriteTable(&b, server.Routes(), f); err != nil {
+		replyError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch f {
+	case route.FormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+	case route.FormatMarkdown:
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Write(b.Bytes())
+}
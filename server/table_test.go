@@ -0,0 +1,56 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAddRouteTable(t *testing.T) {
+	Convey("should serve the route table", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {}).Name("user")
+		So(s.AddRouteTable("/_routes", testMiddleware("auth")).Err(), ShouldBeNil)
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/_routes", nil))
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(w.Header().Get("X-Middleware"), ShouldEqual, "auth")
+		var routes []map[string]interface{}
+		So(json.Unmarshal(w.Body.Bytes(), &routes), ShouldBeNil)
+		So(len(routes), ShouldEqual, 2)
+		So(routes[0]["pattern"], ShouldEqual, "/_routes")
+		So(routes[1]["pattern"], ShouldEqual, "/users/{id}")
+		So(routes[1]["name"], ShouldEqual, "user")
+
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/_routes", nil)
+		r.Header.Set("Accept", "text/plain")
+		s.ServeHTTP(w, r)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+		So(strings.HasPrefix(w.Body.String(), "METHOD"), ShouldBeTrue)
+		So(w.Body.String(), ShouldContainSubstring, "/users/{id}")
+
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/_routes?format=markdown", nil))
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/markdown; charset=utf-8")
+		So(w.Body.String(), ShouldContainSubstring, "| GET | `/users/{id}` | user | handler | - |")
+
+		w = httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/_routes?format=xml", nil))
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+	})
+}
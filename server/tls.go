@@ -0,0 +1,282 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/devfacet/goweb/log"
+)
+
+const (
+	defaultTLSReloadInterval = 10 * time.Second
+)
+
+// TLSCertificate represents a certificate and key file pair
+type TLSCertificate struct {
+	// CertFile holds the certificate file path
+	CertFile string
+	// KeyFile holds the key file path
+	KeyFile string
+}
+
+// certStore represents a set of certificates that are reloaded when their files change
+type certStore struct {
+	mu       sync.RWMutex
+	files    []TLSCertificate
+	certs    []*tls.Certificate
+	modTimes []time.Time
+	interval time.Duration
+	checked  time.Time
+}
+
+// newCertStore returns a new certificate store by the given certificate files
+func newCertStore(files []TLSCertificate, interval time.Duration) (*certStore, error) {
+	// Init the store
+	cs := certStore{
+		files:    files,
+		certs:    make([]*tls.Certificate, len(files)),
+		modTimes: make([]time.Time, len(files)),
+		interval: interval,
+		checked:  time.Now(),
+	}
+
+	if cs.interval == 0 {
+		cs.interval = defaultTLSReloadInterval
+	}
+
+	// Load the certificates
+	for i, v := range files {
+		cert, mt, err := loadCertificate(v)
+		if err != nil {
+			return nil, err
+		}
+		cs.certs[i] = cert
+		cs.modTimes[i] = mt
+	}
+
+	return &cs, nil
+}
+
+// reload reloads the certificates whose files are changed since the last check
+func (cs *certStore) reload() {
+	// If it's not time to check then
+	cs.mu.RLock()
+	skip := cs.interval < 0 || time.Since(cs.checked) < cs.interval
+	cs.mu.RUnlock()
+	if skip {
+		return
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.checked = time.Now()
+
+	// Iterate over the files and reload the changed ones
+	for i, v := range cs.files {
+		mt, err := certModTime(v)
+		if err != nil || !mt.After(cs.modTimes[i]) {
+			continue
+		}
+		cert, mt, err := loadCertificate(v)
+		if err != nil {
+			// Keep the current certificate, files might be in the middle of an update
+			log.Logger.Warn("failed to reload certificate", "file", v.CertFile, "error", err)
+			continue
+		}
+		cs.certs[i] = cert
+		cs.modTimes[i] = mt
+		log.Logger.Info("reloaded certificate", "file", v.CertFile)
+	}
+}
+
+// getCertificate returns the certificate for the given client hello (SNI)
+// The first certificate is returned if there isn't any certificate that supports the client hello.
+func (cs *certStore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := cs.match(hello); cert != nil {
+		return cert, nil
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if len(cs.certs) == 0 {
+		return nil, errors.New("no certificate")
+	}
+	return cs.certs[0], nil
+}
+
+// match returns the certificate that supports the given client hello or nil if there isn't any
+func (cs *certStore) match(hello *tls.ClientHelloInfo) *tls.Certificate {
+	cs.reload()
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	for _, v := range cs.certs {
+		if hello.SupportsCertificate(v) == nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// loadCertificate loads the given certificate files and returns the certificate with its modification time
+func loadCertificate(f TLSCertificate) (*tls.Certificate, time.Time, error) {
+	mt, err := certModTime(f)
+	if err != nil {
+		return nil, mt, fmt.Errorf("failed to load certificate due to %s", err.Error())
+	}
+	cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+	if err != nil {
+		return nil, mt, fmt.Errorf("failed to load certificate due to %s", err.Error())
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, mt, fmt.Errorf("failed to parse certificate due to %s", err.Error())
+		}
+	}
+
+	return &cert, mt, nil
+}
+
+// certModTime returns the latest modification time of the given certificate files
+func certModTime(f TLSCertificate) (time.Time, error) {
+	var result time.Time
+	for _, v := range []string{f.CertFile, f.KeyFile} {
+		fi, err := os.Stat(v)
+		if err != nil {
+			return result, err
+		}
+		if fi.ModTime().After(result) {
+			result = fi.ModTime()
+		}
+	}
+	return result, nil
+}
+
+// SelfSignedCertificate returns a self-signed certificate for the given hosts
+// It's meant for local development and defaults to localhost, 127.0.0.1 and ::1.
+// The certificate can be used as a client certificate too.
+func SelfSignedCertificate(hosts ...string) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+
+	// Generate the key
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key due to %s", err.Error())
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial number due to %s", err.Error())
+	}
+
+	// Create the certificate
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"goweb"}, CommonName: hosts[0]},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, v := range hosts {
+		if ip := net.ParseIP(v); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, v)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate due to %s", err.Error())
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to parse certificate due to %s", err.Error())
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// tlsConfig returns the TLS config of the server or nil if TLS is not enabled
+func (server *Server) tlsConfig() (*tls.Config, error) {
+	// Init vars
+	files := server.tlsCertificates
+	if server.tlsCertFile != "" || server.tlsKeyFile != "" {
+		files = append([]TLSCertificate{{CertFile: server.tlsCertFile, KeyFile: server.tlsKeyFile}}, files...)
+	}
+
+	if server.tls == nil && len(files) == 0 {
+		return nil, nil
+	}
+
+	result := &tls.Config{}
+	if server.tls != nil {
+		result = server.tls.Clone()
+	}
+
+	// If there are certificate files then serve them by SNI
+	if len(files) > 0 {
+		cs, err := newCertStore(files, server.tlsReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		// The GetCertificate function of the config is consulted first, then the certificate files so the
+		// reloaded certificates are served, and the certificates of the config are used when there is no
+		// certificate file for the client hello
+		gc := result.GetCertificate
+		certs := result.Certificates
+		result.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if gc != nil {
+				if cert, err := gc(hello); cert != nil || err != nil {
+					return cert, err
+				}
+			}
+			if cert := cs.match(hello); cert != nil {
+				return cert, nil
+			}
+			for i := range certs {
+				if hello.SupportsCertificate(&certs[i]) == nil {
+					return &certs[i], nil
+				}
+			}
+			return cs.getCertificate(hello)
+		}
+		result.Certificates = nil
+	}
+
+	// Client certificates
+	if server.tlsClientCAs != nil {
+		result.ClientCAs = server.tlsClientCAs
+		result.ClientAuth = server.tlsClientAuth
+		if result.ClientAuth == tls.NoClientCert {
+			result.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	if len(result.Certificates) == 0 && result.GetCertificate == nil && result.GetConfigForClient == nil {
+		return nil, errors.New("invalid tls config due to missing certificate")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,227 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeCertificate(cert tls.Certificate, certFile, keyFile string) error {
+	cb := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	kd, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return err
+	}
+	kb := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: kd})
+	if err := ioutil.WriteFile(certFile, cb, 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyFile, kb, 0600)
+}
+
+func peerCertificate(address, serverName string) (*x509.Certificate, error) {
+	conn, err := tls.Dial("tcp", address, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	Convey("should return a self-signed certificate", t, func() {
+		cert, err := server.SelfSignedCertificate()
+		So(err, ShouldBeNil)
+		So(cert.Leaf.DNSNames[0], ShouldEqual, "localhost")
+		So(len(cert.Leaf.IPAddresses), ShouldEqual, 2)
+
+		cert, err = server.SelfSignedCertificate("foo.local")
+		So(err, ShouldBeNil)
+		So(cert.Leaf.Subject.CommonName, ShouldEqual, "foo.local")
+	})
+}
+
+func TestListenTLS(t *testing.T) {
+	Convey("should listen with TLS certificate files and reload them", t, func() {
+		dir, err := ioutil.TempDir("", "goweb")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		c1, err := server.SelfSignedCertificate("localhost")
+		So(err, ShouldBeNil)
+		So(writeCertificate(c1, filepath.Join(dir, "1.crt"), filepath.Join(dir, "1.key")), ShouldBeNil)
+		c2, err := server.SelfSignedCertificate("foo.local")
+		So(err, ShouldBeNil)
+		So(writeCertificate(c2, filepath.Join(dir, "2.crt"), filepath.Join(dir, "2.key")), ShouldBeNil)
+
+		s := server.New(server.Options{
+			TLSCertFile:       filepath.Join(dir, "1.crt"),
+			TLSKeyFile:        filepath.Join(dir, "1.key"),
+			TLSCertificates:   []server.TLSCertificate{{CertFile: filepath.Join(dir, "2.crt"), KeyFile: filepath.Join(dir, "2.key")}},
+			TLSReloadInterval: time.Nanosecond,
+		})
+		s.AddGet("/foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("foo")) })
+
+		So(s.Start(), ShouldBeNil)
+
+		pool := x509.NewCertPool()
+		pool.AddCert(c1.Leaf)
+		client := http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		resp, err := client.Get(fmt.Sprintf("https://%s/foo", s.Address()))
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "foo")
+
+		pc, err := peerCertificate(s.Address(), "foo.local")
+		So(err, ShouldBeNil)
+		So(pc.Subject.CommonName, ShouldEqual, "foo.local")
+
+		pc, err = peerCertificate(s.Address(), "localhost")
+		So(err, ShouldBeNil)
+		So(pc.SerialNumber.Cmp(c1.Leaf.SerialNumber), ShouldEqual, 0)
+
+		// Replace the certificate files
+		c3, err := server.SelfSignedCertificate("localhost")
+		So(err, ShouldBeNil)
+		So(writeCertificate(c3, filepath.Join(dir, "1.crt"), filepath.Join(dir, "1.key")), ShouldBeNil)
+		mt := time.Now().Add(time.Second)
+		So(os.Chtimes(filepath.Join(dir, "1.crt"), mt, mt), ShouldBeNil)
+
+		pc, err = peerCertificate(s.Address(), "localhost")
+		So(err, ShouldBeNil)
+		So(pc.SerialNumber.Cmp(c3.Leaf.SerialNumber), ShouldEqual, 0)
+
+		s.Close()
+	})
+
+	Convey("should listen with the given TLS config", t, func() {
+		cert, err := server.SelfSignedCertificate()
+		So(err, ShouldBeNil)
+		s := server.New(server.Options{TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}}})
+		s.AddGet("/foo", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("foo")) })
+
+		So(s.Start(), ShouldBeNil)
+
+		pc, err := peerCertificate(s.Address(), "localhost")
+		So(err, ShouldBeNil)
+		So(pc.SerialNumber.Cmp(cert.Leaf.SerialNumber), ShouldEqual, 0)
+
+		s.Close()
+	})
+
+	Convey("should prefer the certificate files over the certificates of the TLS config", t, func() {
+		dir, err := ioutil.TempDir("", "goweb")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		c1, err := server.SelfSignedCertificate("localhost")
+		So(err, ShouldBeNil)
+		So(writeCertificate(c1, filepath.Join(dir, "1.crt"), filepath.Join(dir, "1.key")), ShouldBeNil)
+		c2, err := server.SelfSignedCertificate("localhost")
+		So(err, ShouldBeNil)
+		c3, err := server.SelfSignedCertificate("bar.local")
+		So(err, ShouldBeNil)
+
+		s := server.New(server.Options{
+			TLSCertFile: filepath.Join(dir, "1.crt"),
+			TLSKeyFile:  filepath.Join(dir, "1.key"),
+			TLSConfig:   &tls.Config{Certificates: []tls.Certificate{c2, c3}},
+		})
+		So(s.Start(), ShouldBeNil)
+
+		pc, err := peerCertificate(s.Address(), "localhost")
+		So(err, ShouldBeNil)
+		So(pc.SerialNumber.Cmp(c1.Leaf.SerialNumber), ShouldEqual, 0)
+
+		pc, err = peerCertificate(s.Address(), "bar.local")
+		So(err, ShouldBeNil)
+		So(pc.SerialNumber.Cmp(c3.Leaf.SerialNumber), ShouldEqual, 0)
+
+		s.Close()
+	})
+
+	Convey("should fail to listen due to invalid TLS options", t, func() {
+		s := server.New(server.Options{TLSCertFile: "invalid.crt", TLSKeyFile: "invalid.key"})
+		So(s.Listen(), ShouldBeError, "failed to load certificate due to stat invalid.crt: no such file or directory")
+
+		s = server.New(server.Options{TLSConfig: &tls.Config{}})
+		So(s.Listen(), ShouldBeError, "invalid tls config due to missing certificate")
+	})
+}
+
+func TestClientAuth(t *testing.T) {
+	Convey("should authenticate clients by certificates", t, func() {
+		cert, err := server.SelfSignedCertificate()
+		So(err, ShouldBeNil)
+		c1, err := server.SelfSignedCertificate("service1.internal")
+		So(err, ShouldBeNil)
+		c2, err := server.SelfSignedCertificate("service2.internal")
+		So(err, ShouldBeNil)
+		cas := x509.NewCertPool()
+		cas.AddCert(c1.Leaf)
+		cas.AddCert(c2.Leaf)
+
+		s := server.New(server.Options{
+			TLSConfig:     &tls.Config{Certificates: []tls.Certificate{cert}},
+			TLSClientCAs:  cas,
+			TLSClientAuth: tls.VerifyClientCertIfGiven,
+		})
+		s.AddGet("/whoami", func(w http.ResponseWriter, r *http.Request) {
+			req := request.New(request.Options{Request: r, Writer: w})
+			if ci := req.ClientIdentity(); ci != nil {
+				req.Reply(ci.CommonName)
+				return
+			}
+			req.Reply("anonymous")
+		})
+		s.AddGet("/admin", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("admin")) }, server.RequireClientSubject("service1.internal"))
+
+		So(s.Start(), ShouldBeNil)
+
+		pool := x509.NewCertPool()
+		pool.AddCert(cert.Leaf)
+		for _, v := range []struct {
+			cert   *tls.Certificate
+			path   string
+			status int
+			body   string
+		}{
+			{nil, "/whoami", 200, "anonymous"},
+			{&c1, "/whoami", 200, "service1.internal"},
+			{nil, "/admin", 401, `{"statusCode":401,"message":"Unauthorized"}`},
+			{&c1, "/admin", 200, "admin"},
+			{&c2, "/admin", 403, `{"statusCode":403,"message":"Forbidden"}`},
+		} {
+			tc := &tls.Config{RootCAs: pool}
+			if v.cert != nil {
+				tc.Certificates = []tls.Certificate{*v.cert}
+			}
+			client := http.Client{Transport: &http.Transport{TLSClientConfig: tc}}
+			resp, err := client.Get(fmt.Sprintf("https://%s%s", s.Address(), v.path))
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			b, _ := ioutil.ReadAll(resp.Body)
+			So(resp.StatusCode, ShouldEqual, v.status)
+			So(string(b), ShouldEqual, v.body)
+		}
+
+		s.Close()
+	})
+}
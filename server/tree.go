@@ -0,0 +1,208 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"strings"
+
+	"github.com/devfacet/goweb/route"
+)
+
+// matcher represents a route matcher
+type matcher interface {
+	// add adds the given endpoint
+	add(e *endpoint)
+	// match returns the most specific endpoint and its parameters by the given path
+	match(p string) (*endpoint, map[string]string)
+}
+
+// Matcher represents the kind of the route matcher of a server
+type Matcher string
+
+const (
+	// MatcherTree matches the routes by a radix tree in time proportional to the path length (default)
+	MatcherTree Matcher = "tree"
+	// MatcherLinear matches the routes by comparing all the patterns
+	MatcherLinear Matcher = "linear"
+)
+
+// newMatcher returns a new matcher by the given kind
+func newMatcher(kind Matcher) matcher {
+	if kind == MatcherLinear {
+		return &linear{}
+	}
+	return &tree{root: &node{}}
+}
+
+// linear represents a matcher that compares the path against all the patterns
+type linear struct {
+	endpoints []*endpoint
+}
+
+// add adds the given endpoint
+func (l *linear) add(e *endpoint) {
+	l.endpoints = append(l.endpoints, e)
+}
+
+// match returns the most specific endpoint and its parameters by the given path
+func (l *linear) match(p string) (*endpoint, map[string]string) {
+	// Init vars
+	var result *endpoint
+	var params map[string]string
+	parts := splitPath(p)
+
+	// Iterate over the endpoints and find the most specific one
+	for _, v := range l.endpoints {
+		if pm, ok := matchSegments(v.segments, parts); ok {
+			if result == nil || route.CompareSegments(v.segments, result.segments) > 0 {
+				result = v
+				params = pm
+			}
+		}
+	}
+
+	return result, params
+}
+
+// tree represents a radix tree matcher
+// Static parts of the patterns are compressed into the nodes, and the parameters and the wildcards
+// are kept on the nodes that end with a slash. Static nodes are preferred over the parameters and
+// the parameters over the wildcards which gives the same precedence as the linear matcher.
+type tree struct {
+	root *node
+}
+
+// node represents a node of the radix tree
+type node struct {
+	prefix   string
+	indices  string
+	children []*node
+	param    *node     // matches a non-empty path segment
+	endpoint *endpoint // matches the path that ends at the node
+	wildcard *endpoint // matches the rest of the path by a named wildcard
+	subtree  *endpoint // matches the rest of the path by a trailing slash
+}
+
+// add adds the given endpoint
+func (t *tree) add(e *endpoint) {
+	// Init vars
+	n := t.root
+	static := ""
+
+	for _, v := range e.segments {
+		static += "/"
+		switch v.Kind {
+		case route.SegmentStatic:
+			static += v.Value
+		case route.SegmentParam:
+			n = n.static(static)
+			static = ""
+			if n.param == nil {
+				n.param = &node{}
+			}
+			n = n.param
+		case route.SegmentWildcard:
+			n = n.static(static)
+			if v.Value == "" {
+				n.subtree = e
+			} else {
+				n.wildcard = e
+			}
+			return
+		}
+	}
+	n.static(static).endpoint = e
+}
+
+// match returns the most specific endpoint and its parameters by the given path
+func (t *tree) match(p string) (*endpoint, map[string]string) {
+	e, values := t.root.match(p, nil)
+	if e == nil || len(values) == 0 {
+		return e, nil
+	}
+
+	// Map the parameter values to the names of the endpoint
+	params := make(map[string]string, len(values))
+	i := 0
+	for _, v := range e.segments {
+		if v.Kind == route.SegmentParam || (v.Kind == route.SegmentWildcard && v.Value != "") {
+			params[v.Value] = values[i]
+			i++
+		}
+	}
+
+	return e, params
+}
+
+// static returns the node of the given static path under the node by creating it if it doesn't exist
+func (n *node) static(s string) *node {
+	for s != "" {
+		i := strings.IndexByte(n.indices, s[0])
+		if i < 0 {
+			child := &node{prefix: s}
+			n.indices += s[:1]
+			n.children = append(n.children, child)
+			return child
+		}
+
+		// Split the child if the path diverges from its prefix
+		child := n.children[i]
+		l := 0
+		for l < len(s) && l < len(child.prefix) && s[l] == child.prefix[l] {
+			l++
+		}
+		if l < len(child.prefix) {
+			split := *child
+			split.prefix = child.prefix[l:]
+			*child = node{prefix: child.prefix[:l], indices: split.prefix[:1], children: []*node{&split}}
+		}
+		n = child
+		s = s[l:]
+	}
+
+	return n
+}
+
+// match returns the endpoint and the parameter values by the rest of the path
+func (n *node) match(p string, values []string) (*endpoint, []string) {
+	if p == "" && n.endpoint != nil {
+		return n.endpoint, values
+	}
+
+	if p != "" {
+		// Static nodes
+		if i := strings.IndexByte(n.indices, p[0]); i >= 0 {
+			if c := n.children[i]; strings.HasPrefix(p, c.prefix) {
+				if e, v := c.match(p[len(c.prefix):], values); e != nil {
+					return e, v
+				}
+			}
+		}
+
+		// Parameters
+		if n.param != nil {
+			end := strings.IndexByte(p, '/')
+			if end < 0 {
+				end = len(p)
+			}
+			if end > 0 {
+				if e, v := n.param.match(p[end:], append(values, p[:end])); e != nil {
+					return e, v
+				}
+			}
+		}
+	}
+
+	// Wildcards
+	if n.wildcard != nil {
+		return n.wildcard, append(values, p)
+	}
+	if n.subtree != nil {
+		return n.subtree, values
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,185 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var matcherPatterns = []string{
+	"/",
+	"/about",
+	"/about/",
+	"/users",
+	"/users/",
+	"/users/new",
+	"/users/{id}",
+	"/users/{id}/posts/",
+	"/users/{id}/posts/{post}",
+	"/usage/{kind}",
+	"/files/{path...}",
+	"/docs/",
+	"/docs/api/{version}/",
+	"/{lang}/home",
+	"/static/",
+}
+
+// newMatcherServer returns a server with the matcher patterns by the given matcher
+func newMatcherServer(m server.Matcher) *server.Server {
+	s := server.New(server.Options{Matcher: m})
+	for _, v := range matcherPatterns {
+		pattern := v
+		s.AddGet(pattern, func(w http.ResponseWriter, r *http.Request) {
+			params := request.New(request.Options{Request: r}).Params()
+			keys := []string{}
+			for k, v := range params {
+				keys = append(keys, k+"="+v)
+			}
+			sort.Strings(keys)
+			w.Write([]byte(pattern + " " + strings.Join(keys, ",")))
+		})
+	}
+	return s
+}
+
+func TestMatcher(t *testing.T) {
+	Convey("should match the same routes by the tree and linear matchers", t, func() {
+		tree := newMatcherServer(server.MatcherTree)
+		linear := newMatcherServer(server.MatcherLinear)
+		So(tree.Err(), ShouldBeNil)
+
+		get := func(s *server.Server, path string) string {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			if w.Code == http.StatusMovedPermanently {
+				return fmt.Sprintf("%d %s", w.Code, w.Header().Get("Location"))
+			}
+			return fmt.Sprintf("%d %s", w.Code, strings.TrimSpace(w.Body.String()))
+		}
+
+		cases := map[string]string{
+			"/":                       "200 /",
+			"/about":                  "200 /about",
+			"/about/":                 "200 /about/",
+			"/users/new":              "200 /users/new",
+			"/users/1":                "200 /users/{id} id=1",
+			"/users/1/posts":          "301 /users/1/posts/",
+			"/users/1/posts/":         "200 /users/{id}/posts/ id=1",
+			"/users/1/posts/2":        "200 /users/{id}/posts/{post} id=1,post=2",
+			"/users/1/posts/2/3":      "200 /users/{id}/posts/ id=1",
+			"/usage/cpu":              "200 /usage/{kind} kind=cpu",
+			"/usage/":                 "200 /",
+			"/files/a/b/c.txt":        "200 /files/{path...} path=a/b/c.txt",
+			"/files/":                 "200 /files/{path...} path=",
+			"/files":                  "200 /",
+			"/docs":                   "301 /docs/",
+			"/docs/api/v1":            "301 /docs/api/v1/",
+			"/docs/api/v1/x":          "200 /docs/api/{version}/ version=v1",
+			"/docs/api":               "200 /docs/",
+			"/en/home":                "200 /{lang}/home lang=en",
+			"/users/home":             "200 /users/{id} id=home",
+			"/static":                 "301 /static/",
+			"/static/css/app.css":     "200 /static/",
+			"/unknown/path":           "200 /",
+			"/users/1/../../about":    "301 /about",
+			"/users/%E2%9C%93/posts/": "200 /users/{id}/posts/ id=✓",
+		}
+		for path, expected := range cases {
+			Convey(path, func() {
+				So(get(tree, path), ShouldEqual, expected)
+				So(get(linear, path), ShouldEqual, expected)
+			})
+		}
+	})
+
+	Convey("should report the conflicting routes", t, func() {
+		s := server.New(server.Options{})
+		So(s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {}).Err(), ShouldBeNil)
+		So(s.AddGet("/users/{name}", func(w http.ResponseWriter, r *http.Request) {}).Err(), ShouldBeError, "failed to add route due to pattern /users/{name} conflicts with /users/{id}")
+		So(s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {}).Err(), ShouldBeError, "failed to add route due to multiple registrations for GET /users/{id}")
+		So(s.AddGet("/files/{path...}/x", func(w http.ResponseWriter, r *http.Request) {}).Err(), ShouldNotBeNil)
+		So(len(s.Routes()), ShouldEqual, 1)
+		So(s.Err(), ShouldNotBeNil)
+		So(s.Start(), ShouldBeError, s.Err().Error())
+		So(s.State(), ShouldEqual, server.StateNew)
+	})
+
+	Convey("should report the subtree and wildcard routes matching the same paths", t, func() {
+		for _, v := range []server.Matcher{server.MatcherTree, server.MatcherLinear} {
+			s := server.New(server.Options{Matcher: v})
+			So(s.AddGet("/a/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("/a/")) }).Err(), ShouldBeNil)
+			So(s.AddGet("/a/{p...}", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("/a/{p...}")) }).Err(), ShouldBeError, "failed to add route due to pattern /a/{p...} conflicts with /a/")
+
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("GET", "/a/x", nil))
+			So(w.Body.String(), ShouldEqual, "/a/")
+		}
+	})
+}
+
+// benchmarkPaths holds the paths for the benchmarks
+var benchmarkPaths = []string{"/about", "/users/new", "/users/1/posts/2", "/files/a/b/c.txt"}
+
+// benchmarkServe serves the benchmark paths by the given handler
+func benchmarkServe(b *testing.B, h http.Handler, path string) {
+	r := httptest.NewRequest("GET", path, nil)
+	w := &discardWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(w, r)
+	}
+}
+
+// discardWriter represents a response writer that discards the response
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// newBenchmarkServer returns a server with the matcher patterns and no-op handlers
+func newBenchmarkServer(m server.Matcher) *server.Server {
+	s := server.New(server.Options{Matcher: m})
+	for _, v := range matcherPatterns {
+		s.AddGet(v, func(w http.ResponseWriter, r *http.Request) {})
+	}
+	return s
+}
+
+// newBenchmarkServeMux returns an http.ServeMux with the matcher patterns and no-op handlers
+func newBenchmarkServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, v := range matcherPatterns {
+		if v == "/{lang}/home" {
+			continue // conflicts with /users/{id} on http.ServeMux
+		}
+		mux.HandleFunc("GET "+v, func(w http.ResponseWriter, r *http.Request) {})
+	}
+	return mux
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	tree := newBenchmarkServer(server.MatcherTree)
+	linear := newBenchmarkServer(server.MatcherLinear)
+	mux := newBenchmarkServeMux()
+	for _, v := range benchmarkPaths {
+		b.Run("tree"+v, func(b *testing.B) { benchmarkServe(b, tree, v) })
+		b.Run("linear"+v, func(b *testing.B) { benchmarkServe(b, linear, v) })
+		b.Run("servemux"+v, func(b *testing.B) { benchmarkServe(b, mux, v) })
+	}
+}
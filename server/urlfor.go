@@ -0,0 +1,186 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/devfacet/goweb/log"
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/route"
+)
+
+// Registration represents a registered route
+// It's returned by the handler registration functions for naming the route (i.e. `AddGet(...).Name("user")`).
+type Registration struct {
+	server    *Server
+	key       string
+	routeName string
+	err       error
+}
+
+// Err returns the error of the registration (i.e. the pattern conflicts with another one)
+func (reg *Registration) Err() error {
+	return reg.err
+}
+
+// Name sets the name of the route for building URLs (see Server.URLFor)
+// If the name is already used by another route then the error is reported by Err and Server.Err.
+func (reg *Registration) Name(name string) *Registration {
+	reg.name(name)
+	return reg
+}
+
+// name sets the name of the route
+func (reg *Registration) name(name string) error {
+	if reg.err != nil {
+		return reg.err
+	}
+
+	reg.server.routesMu.Lock()
+	defer reg.server.routesMu.Unlock()
+
+	if k, ok := reg.server.names[name]; ok && k != reg.key {
+		reg.err = fmt.Errorf("multiple registrations for route name %s", name)
+		reg.server.routeErrs = append(reg.server.routeErrs, reg.err)
+		log.Logger.Error("failed to name route", "server", reg.server.id, "name", name, "error", reg.err)
+		return reg.err
+	}
+	if reg.routeName != "" {
+		delete(reg.server.names, reg.routeName)
+	}
+	reg.routeName = name
+	reg.server.names[name] = reg.key
+	o := reg.server.routes[reg.key]
+	o.Name = name
+	reg.server.routes[reg.key] = o
+
+	return nil
+}
+
+// withName sets the route name into the request context
+func (reg *Registration) withName(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.server.routesMu.RLock()
+		name := reg.routeName
+		reg.server.routesMu.RUnlock()
+		if name != "" {
+			r = r.WithContext(context.WithValue(r.Context(), request.ContextKeys.RouteName, name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// URLFor returns the URL path of the given named route by the given parameter names and values
+// i.e. `URLFor("user", "id", 1)` returns "/users/1" for the route "/users/{id}".
+// Routes of the mounted servers are resolved with their mount prefixes.
+// It returns an error if the route is not found or a parameter is missing or unknown.
+func (server *Server) URLFor(name string, params ...interface{}) (string, error) {
+	// Init vars
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("failed to build url for %s due to odd number of parameters", name)
+	}
+	values := map[string]string{}
+	for i := 0; i < len(params); i += 2 {
+		k, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("failed to build url for %s due to invalid parameter name %v", name, params[i])
+		}
+		values[k] = fmt.Sprint(params[i+1])
+	}
+
+	result, ok, err := server.urlFor(name, values)
+	if err != nil {
+		return "", err
+	} else if !ok {
+		return "", fmt.Errorf("failed to build url for %s due to unknown route", name)
+	}
+
+	return result, nil
+}
+
+// urlFor returns the URL path of the given named route and whether the route is found or not
+func (server *Server) urlFor(name string, values map[string]string) (string, bool, error) {
+	server.routesMu.RLock()
+	defer server.routesMu.RUnlock()
+
+	if k, ok := server.names[name]; ok {
+		result, err := buildURL(server.routes[k].Path, values)
+		if err != nil {
+			return "", true, fmt.Errorf("failed to build url for %s due to %s", name, err.Error())
+		}
+		return result, true, nil
+	}
+
+	// Mounted servers
+	for _, m := range server.mounts {
+		if result, ok, err := m.server.urlFor(name, values); ok {
+			return m.prefix + result, true, err
+		}
+	}
+
+	return "", false, nil
+}
+
+// buildURL builds the URL path of the given pattern by the given parameters
+func buildURL(pattern string, values map[string]string) (string, error) {
+	// Init vars
+	segs, err := route.ParsePattern(pattern)
+	if err != nil {
+		return "", err
+	}
+	used := 0
+	var b strings.Builder
+
+	for _, v := range segs {
+		b.WriteByte('/')
+		switch v.Kind {
+		case route.SegmentStatic:
+			b.WriteString(v.Value)
+		case route.SegmentParam, route.SegmentWildcard:
+			if v.Value == "" {
+				continue // subtree
+			}
+			pv, ok := values[v.Value]
+			if !ok {
+				return "", fmt.Errorf("missing parameter %s", v.Value)
+			}
+			used++
+			if v.Kind == route.SegmentParam {
+				if pv == "" {
+					return "", fmt.Errorf("empty parameter %s", v.Value)
+				}
+				b.WriteString(url.PathEscape(pv))
+				continue
+			}
+			// Wildcard values keep their slashes
+			parts := strings.Split(pv, "/")
+			for i, p := range parts {
+				parts[i] = url.PathEscape(p)
+			}
+			b.WriteString(strings.Join(parts, "/"))
+		}
+	}
+
+	// Report the parameters that are not in the pattern
+	if used != len(values) {
+		names := []string{}
+		for k := range values {
+			if !strings.Contains(pattern, "{"+k+"}") && !strings.Contains(pattern, "{"+k+"...}") {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown parameter %s", strings.Join(names, ", "))
+	}
+
+	return b.String(), nil
+}
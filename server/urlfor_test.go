@@ -0,0 +1,112 @@
+/*
+ * goweb
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/devfacet/goweb/page"
+	"github.com/devfacet/goweb/request"
+	"github.com/devfacet/goweb/server"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestURLFor(t *testing.T) {
+	Convey("should build the URLs of the named routes", t, func() {
+		s := server.New(server.Options{PathPrefix: "app"})
+		s.AddGet("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			req := request.New(request.Options{Request: r})
+			u, _ := req.URLFor("file", "path", "a b/c.txt")
+			w.Write([]byte(req.RouteName() + " " + u))
+		}).Name("user")
+		s.Group("/files").AddGet("/{path...}", func(w http.ResponseWriter, r *http.Request) {}).Name("file")
+		s.AddGet("/docs/", func(w http.ResponseWriter, r *http.Request) {}).Name("docs")
+		p, err := page.New(page.Options{URLPath: "/", Name: "home", Content: `{{urlfor "user" "id" 1}}`})
+		So(err, ShouldBeNil)
+		So(s.AddPage(p), ShouldBeNil)
+
+		u, err := s.URLFor("user", "id", 1)
+		So(err, ShouldBeNil)
+		So(u, ShouldEqual, "/app/users/1")
+		u, err = s.URLFor("user", "id", "a/b")
+		So(err, ShouldBeNil)
+		So(u, ShouldEqual, "/app/users/a%2Fb")
+		u, err = s.URLFor("file", "path", "a b/c.txt")
+		So(err, ShouldBeNil)
+		So(u, ShouldEqual, "/app/files/a%20b/c.txt")
+		u, err = s.URLFor("docs")
+		So(err, ShouldBeNil)
+		So(u, ShouldEqual, "/app/docs/")
+		u, err = s.URLFor("home")
+		So(err, ShouldBeNil)
+		So(u, ShouldEqual, "/app/")
+
+		_, err = s.URLFor("user")
+		So(err, ShouldBeError, "failed to build url for user due to missing parameter id")
+		_, err = s.URLFor("user", "id", 1, "foo", 2)
+		So(err, ShouldBeError, "failed to build url for user due to unknown parameter foo")
+		_, err = s.URLFor("user", "id")
+		So(err, ShouldBeError, "failed to build url for user due to odd number of parameters")
+		_, err = s.URLFor("missing")
+		So(err, ShouldBeError, "failed to build url for missing due to unknown route")
+
+		names := map[string]string{}
+		for _, v := range s.Routes() {
+			names[v.Path()] = v.Name()
+		}
+		So(names["/app/users/{id}"], ShouldEqual, "user")
+		So(names["/app/"], ShouldEqual, "home")
+
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/app/users/1")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "user /app/files/a%20b/c.txt")
+
+		resp, err = http.Get(ts.URL + "/app/")
+		So(err, ShouldBeNil)
+		defer resp.Body.Close()
+		b, _ = ioutil.ReadAll(resp.Body)
+		So(string(b), ShouldEqual, "/app/users/1")
+	})
+
+	Convey("should build the URLs of the mounted servers", t, func() {
+		blog := server.New(server.Options{})
+		blog.AddGet("/posts/{id}", func(w http.ResponseWriter, r *http.Request) {
+			u, _ := request.New(request.Options{Request: r}).URLFor("post", "id", 2)
+			w.Write([]byte(u))
+		}).Name("post")
+		s := server.New(server.Options{})
+		s.Mount("/blog", blog)
+
+		u, err := s.URLFor("post", "id", 1)
+		So(err, ShouldBeNil)
+		So(u, ShouldEqual, "/blog/posts/1")
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/blog/posts/1", nil))
+		So(w.Body.String(), ShouldEqual, "/blog/posts/2")
+	})
+
+	Convey("should fail to name a route twice", t, func() {
+		s := server.New(server.Options{})
+		s.AddGet("/foo", func(w http.ResponseWriter, r *http.Request) {}).Name("foo")
+		p, err := page.New(page.Options{URLPath: "/bar", Name: "foo"})
+		So(err, ShouldBeNil)
+		So(s.AddPage(p), ShouldBeError, "multiple registrations for route name foo")
+
+		reg := s.AddGet("/baz", func(w http.ResponseWriter, r *http.Request) {}).Name("foo")
+		So(reg.Err(), ShouldBeError, "multiple registrations for route name foo")
+		So(s.Err(), ShouldBeError, "multiple registrations for route name foo\nmultiple registrations for route name foo")
+		So(s.Start(), ShouldNotBeNil)
+	})
+}